@@ -0,0 +1,46 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/thatgurkangurk/packwiz-installer/core"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the store of downloads shared between installations",
+}
+
+var cacheGcCmd = &cobra.Command{
+	Use:   "gc [flags]",
+	Short: "Remove stored files that no installation uses any more",
+	Args:  exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := cmd.Flag("store-dir").Value.String()
+		if dir == "" {
+			return fmt.Errorf("no --store-dir given and no cache directory found")
+		}
+		store := &core.Store{Dir: dir}
+		removed, size, err := store.GC()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Store:", dir)
+		fmt.Printf("Removed %d %s, %.1f MiB.\n", removed, pluralize("file", removed), float64(size)/(1<<20))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheGcCmd)
+
+	cacheCmd.PersistentFlags().String("store-dir", core.DefaultStoreDir(), "Directory of the store")
+}
@@ -0,0 +1,136 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thatgurkangurk/packwiz-installer/core"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export an installed instance or a modpack to another format",
+}
+
+var exportMrpackCmd = &cobra.Command{
+	Use:   "mrpack [flags] OUTPUT",
+	Short: "Export to a Modrinth modpack (.mrpack)",
+	Args:  exactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExport(cmd, args[0], core.ExportMrpack)
+	},
+}
+
+var exportCurseforgeCmd = &cobra.Command{
+	Use:     "curseforge [flags] OUTPUT",
+	Aliases: []string{"cf"},
+	Short:   "Export to a CurseForge modpack zip (manifest.json)",
+	Args:    exactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExport(cmd, args[0], core.ExportCurseforge)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportMrpackCmd)
+	exportCmd.AddCommand(exportCurseforgeCmd)
+
+	exportCmd.PersistentFlags().StringP("dir", "d", ".", "Directory of the installed instance to export")
+	exportCmd.PersistentFlags().StringP("pack", "p", "", "URL or path of a modpack to export instead of an installed instance")
+	exportCmd.PersistentFlags().String("hash", "", `Hash of the --pack in the form of "<format>:<hash>" e.g. "sha256:abc012..."`)
+	exportCmd.PersistentFlags().StringP("game-side", "g", "both", "Game side to export the --pack for: 'client', 'server', or 'both'")
+	addJobsFlags(exportCmd.PersistentFlags())
+}
+
+func runExport(cmd *cobra.Command, out string, export func(io.Writer, *core.LocalInstaller) error) error {
+	inst, cleanup, err := exportInstaller(cmd)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	err = export(f, inst)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out)
+		return err
+	}
+
+	fmt.Println("Exported:", out)
+	return nil
+}
+
+// exportInstaller returns the installed instance to export. When --pack is
+// given, the whole pack is installed into a temporary directory first so
+// that every file can be hashed and copied from disk, including optional
+// mods that are off by default.
+func exportInstaller(cmd *cobra.Command) (*core.LocalInstaller, func(), error) {
+	packArg := cmd.Flag("pack").Value.String()
+	if packArg == "" {
+		inst, err := core.LoadLocalInstaller(cmd.Flag("dir").Value.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		return inst, func() {}, nil
+	}
+
+	packUrl, err := core.ParsePackUrl(packArg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --pack: %w", err)
+	}
+	hformat, hhash, ok := parseHashFlag(cmd.Flag("hash").Value.String())
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid --hash format <HashFormat>:<Hash>")
+	}
+	gameSide := core.Side(cmd.Flag("game-side").Value.String())
+	if !gameSide.IsValid() {
+		return nil, nil, fmt.Errorf("invalid --game-side value, must be 'client', 'server', or 'both'")
+	}
+
+	opts, err := repoOptions(cmd, packUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	pack, err := core.LoadPack(cmd.Context(), packUrl, hformat, hhash, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer pack.Close()
+
+	dir, err := os.MkdirTemp("", "packwiz-installer-export-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	inst, err := core.NewLocalInstaller(pack, dir, gameSide)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := setJobs(cmd, inst); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := inst.InstallAll(cmd.Context()); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return inst, cleanup, nil
+}
@@ -7,8 +7,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"net/url"
+	"path/filepath"
 	"slices"
 	"strings"
 
@@ -18,15 +19,15 @@ import (
 
 // installCmd represents the install command
 var installCmd = &cobra.Command{
-	Use:     "install [flags] URL",
+	Use:     "install [flags] URL|PATH",
 	Aliases: []string{"i"},
-	Short:   "Install and update a packwiz modpack",
+	Short:   "Install and update a packwiz or Modrinth modpack",
 	Args:    exactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// args
-		packUrl, err := url.ParseRequestURI(args[0])
+		packUrl, err := core.ParsePackUrl(args[0])
 		if err != nil {
-			return fmt.Errorf("the install command requires URL of 'pack.toml'")
+			return fmt.Errorf("the install command requires URL or path of 'pack.toml'")
 		}
 		// flags
 		var (
@@ -41,29 +42,66 @@ var installCmd = &cobra.Command{
 			}
 		}
 
-		repo := core.NewRepository(packUrl, hformat, hhash)
-		err = repo.Load(cmd.Context())
+		// Validate game side flag
+		gameSide := core.Side(cmd.Flag("game-side").Value.String())
+		if !gameSide.IsValid() {
+			return fmt.Errorf("invalid --game-side value, must be 'client', 'server', or 'both'")
+		}
+
+		dir := cmd.Flag("dir").Value.String()
+		enable, _ := cmd.Flags().GetStringArray("enable")
+		disable, _ := cmd.Flags().GetStringArray("disable")
+		force, _ := cmd.Flags().GetBool("force")
+
+		opts, err := repoOptions(cmd, packUrl)
 		if err != nil {
 			return err
 		}
-		pack, err := core.NewPack(repo)
+		opts = append(opts, core.WithCacheDir(filepath.Join(dir, ".pw-install", "cache")))
+		// changed options always need a full run
+		if !force && len(enable) == 0 && len(disable) == 0 {
+			opts = append(opts, core.WithSkipInstalled(dir, gameSide))
+		}
+		pack, err := core.LoadPack(cmd.Context(), packUrl, hformat, hhash, opts...)
+		if errors.Is(err, core.ErrUpToDate) {
+			fmt.Println("URL:", core.RedactUrl(packUrl.String()))
+			fmt.Println("Up to date.")
+			return nil
+		}
 		if err != nil {
 			return err
 		}
+		defer pack.Close()
 
-		// Validate game side flag
-		gameSide := core.Side(cmd.Flag("game-side").Value.String())
-		if !gameSide.IsValid() {
-			return fmt.Errorf("invalid --game-side value, must be 'client', 'server', or 'both'")
-		}
-
-		inst, err := core.NewLocalInstaller(pack, cmd.Flag("dir").Value.String(), gameSide)
+		inst, err := core.NewLocalInstaller(pack, dir, gameSide)
 		if err != nil {
 			return err
 		}
+		if err := setJobs(cmd, inst); err != nil {
+			return err
+		}
+		if err := setStore(cmd, inst); err != nil {
+			return err
+		}
+		inst.Deep = force
 
-		fmt.Println("URL:", packUrl)
+		// optional mods
+		for _, name := range enable {
+			if err := inst.SetOption(name, true); err != nil {
+				return err
+			}
+		}
+		for _, name := range disable {
+			if err := inst.SetOption(name, false); err != nil {
+				return err
+			}
+		}
+
+		fmt.Println("URL:", core.RedactUrl(packUrl.String()))
 		fmt.Println("Dir:", inst.BaseDir)
+		if pack.Revision != "" {
+			fmt.Println("Revision:", pack.Revision)
+		}
 
 		updates, err := inst.Install(cmd.Context())
 		if err != nil {
@@ -71,6 +109,9 @@ var installCmd = &cobra.Command{
 		}
 
 		fmt.Println(updates.String())
+		if err := printOptions(inst); err != nil {
+			return err
+		}
 		fmt.Println("Done.")
 
 		return nil
@@ -80,9 +121,45 @@ var installCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(installCmd)
 
-	installCmd.Flags().String("hash", "", `Hash of 'pack.toml' in the form of "<format>:<hash>" e.g. "sha256:abc012..."`)
+	installCmd.Flags().String("hash", "", `Hash of 'pack.toml' (or the .mrpack file) in the form of "<format>:<hash>" e.g. "sha256:abc012..."`)
 	installCmd.Flags().StringP("dir", "d", ".", "Directory to install the modpack to")
 	installCmd.Flags().StringP("game-side", "g", "both", "Game side to install mods for: 'client', 'server', or 'both'")
+	installCmd.Flags().StringArray("enable", nil, "Name of an optional mod to install, can be repeated")
+	installCmd.Flags().StringArray("disable", nil, "Name of an optional mod not to install, can be repeated")
+	installCmd.Flags().Bool("force", false, "Hash every file, even if neither the pack nor the file changed since the last install")
+	addJobsFlags(installCmd.Flags())
+	addStoreFlags(installCmd.Flags())
+}
+
+func printOptions(inst *core.LocalInstaller) error {
+	var header bool
+	for _, m := range inst.Pack.Mods {
+		if !m.IsOptional() || !inst.GameSide.ShouldInstall(m.Side) {
+			continue
+		}
+		if !header {
+			fmt.Println("Optional:")
+			header = true
+		}
+
+		enabled, err := inst.IsEnabled(m)
+		if err != nil {
+			return err
+		}
+		mark := " "
+		if enabled {
+			mark = "x"
+		}
+		if m.Option.Description != "" {
+			fmt.Printf("  [%s] %s - %s\n", mark, m.OptionName(), m.Option.Description)
+		} else {
+			fmt.Printf("  [%s] %s\n", mark, m.OptionName())
+		}
+	}
+	if header {
+		fmt.Println()
+	}
+	return nil
 }
 
 func parseHashFlag(s string) (format string, hash string, ok bool) {
@@ -0,0 +1,76 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/thatgurkangurk/packwiz-installer/core"
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy [flags] URL|PATH...",
+	Short: "Serve the downloads of packs to installers on the local network",
+	Long: `Runs a caching proxy for the downloads of the given packs. Every file is downloaded once,
+verified against the hash from its pack and kept in the store, installers using
+--proxy-cache get it from there.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := cmd.Flag("store-dir").Value.String()
+		if dir == "" {
+			return fmt.Errorf("no --store-dir given and no cache directory found")
+		}
+		proxy := core.NewCacheProxy(&core.Store{Dir: dir})
+
+		for _, arg := range args {
+			packUrl, err := core.ParsePackUrl(arg)
+			if err != nil {
+				return fmt.Errorf("the proxy command requires URLs or paths of 'pack.toml'")
+			}
+			opts, err := repoOptions(cmd, packUrl)
+			if err != nil {
+				return err
+			}
+			pack, err := core.LoadPack(cmd.Context(), packUrl, "", "", opts...)
+			if err != nil {
+				return err
+			}
+			defer pack.Close()
+			if err := proxy.AddPack(pack); err != nil {
+				return err
+			}
+			fmt.Println("Pack:", core.RedactUrl(packUrl.String()))
+		}
+
+		if prefetch, _ := cmd.Flags().GetBool("prefetch"); prefetch {
+			jobs, err := cmd.Flags().GetInt("jobs")
+			if err != nil {
+				return err
+			}
+			if err := proxy.Prefetch(cmd.Context(), jobs); err != nil {
+				fmt.Println("Some files failed to prefetch:")
+				fmt.Println(err)
+			}
+		}
+
+		addr := cmd.Flag("listen").Value.String()
+		fmt.Println("Store:", dir)
+		fmt.Println("Listening on", addr)
+		return http.ListenAndServe(addr, proxy)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+
+	proxyCmd.Flags().String("listen", ":8080", "Address to listen on")
+	proxyCmd.Flags().String("store-dir", core.DefaultStoreDir(), "Directory of the store")
+	proxyCmd.Flags().Bool("prefetch", false, "Download every file before serving, instead of on the first request")
+	proxyCmd.Flags().IntP("jobs", "j", core.DefaultJobs, "Number of files prefetched at once")
+}
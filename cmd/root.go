@@ -7,9 +7,11 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/thatgurkangurk/packwiz-installer/core"
 	"github.com/thatgurkangurk/packwiz-installer/pkg/build"
 )
 
@@ -32,4 +34,37 @@ func Execute() {
 
 func init() {
 	rootCmd.SetVersionTemplate("{{.Version}}\n")
+
+	p := core.DefaultRetryPolicy()
+	rootCmd.PersistentFlags().Int("retries", p.Retries, "Number of times a failed request is retried")
+	rootCmd.PersistentFlags().Duration("retry-delay", p.MinDelay, "Delay before the first retry, doubled for every further retry")
+	rootCmd.PersistentFlags().Duration("retry-max-delay", p.MaxDelay, "Longest delay between retries")
+}
+
+// retryPolicy returns the retry policy configured by the flags.
+func retryPolicy(cmd *cobra.Command) (*core.RetryPolicy, error) {
+	retries, err := cmd.Flags().GetInt("retries")
+	if err != nil {
+		return nil, err
+	}
+	minDelay, err := cmd.Flags().GetDuration("retry-delay")
+	if err != nil {
+		return nil, err
+	}
+	maxDelay, err := cmd.Flags().GetDuration("retry-max-delay")
+	if err != nil {
+		return nil, err
+	}
+	if retries < 0 || minDelay < 0 {
+		return nil, fmt.Errorf("invalid retry flags, --retries and --retry-delay must not be negative")
+	}
+	if maxDelay < minDelay {
+		return nil, fmt.Errorf("invalid retry flags, --retry-max-delay must not be less than --retry-delay")
+	}
+
+	return &core.RetryPolicy{
+		Retries:  retries,
+		MinDelay: minDelay,
+		MaxDelay: maxDelay,
+	}, nil
 }
@@ -0,0 +1,50 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/thatgurkangurk/packwiz-installer/core"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [flags]",
+	Short: "Check the installed files against the hashes of the pack",
+	Args:  exactArgs(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inst, err := core.LoadLocalInstaller(cmd.Flag("dir").Value.String())
+		if err != nil {
+			return err
+		}
+		inst.Deep, _ = cmd.Flags().GetBool("deep")
+
+		failed, err := inst.Verify()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Dir:", inst.BaseDir)
+		if len(failed) == 0 {
+			fmt.Printf("%d %s verified.\n", len(inst.Pack.Mods), pluralize("file", len(inst.Pack.Mods)))
+			return nil
+		}
+		fmt.Println("Missing or changed:")
+		for _, m := range failed {
+			fmt.Printf("  %s\n", m.Path)
+		}
+		return fmt.Errorf("%d %s do not match the pack, install with --force to repair them", len(failed), pluralize("file", len(failed)))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringP("dir", "d", ".", "Directory the modpack is installed in")
+	verifyCmd.Flags().Bool("deep", false, "Hash every file, even if its size and modification time did not change")
+}
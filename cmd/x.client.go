@@ -0,0 +1,164 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thatgurkangurk/packwiz-installer/core"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Duration("connect-timeout", 30*time.Second, "Timeout for connecting to a server, 0 for none")
+	rootCmd.PersistentFlags().Duration("read-timeout", time.Minute, "Timeout for receiving data from a server, 0 for none")
+	rootCmd.PersistentFlags().String("proxy", "", "Proxy URL, defaults to the HTTP_PROXY and HTTPS_PROXY environment variables")
+	rootCmd.PersistentFlags().StringArray("ca-file", nil, "PEM file of additional trusted certificate authorities, can be repeated")
+	rootCmd.PersistentFlags().String("client-cert", "", "PEM file of a client certificate")
+	rootCmd.PersistentFlags().String("client-key", "", "PEM file of the key of --client-cert")
+	rootCmd.PersistentFlags().String("user-agent", "", "Text appended to the user agent")
+	rootCmd.PersistentFlags().String("limit-rate", "", `Limit of the combined download rate in bytes per second, with an optional "K", "M" or "G" suffix e.g. "5M"`)
+
+	rootCmd.PersistentFlags().String("token", "", "Bearer token sent to the host of the pack, defaults to $PACKWIZ_TOKEN")
+	rootCmd.PersistentFlags().String("user", "", `Basic auth credentials "<user>:<password>" sent to the host of the pack`)
+	rootCmd.PersistentFlags().Bool("netrc", false, "Send the credentials of ~/.netrc (or $NETRC) to the hosts they are for")
+	rootCmd.PersistentFlags().String("netrc-file", "", "netrc file with credentials for other hosts, implies --netrc")
+
+	rootCmd.PersistentFlags().StringArray("rewrite", nil, `Rule redirecting URLs to mirrors "<prefix> -> <mirror>[, <mirror>...]", can be repeated`)
+}
+
+// repoOptions returns the options for loading the pack at packUrl
+// configured by the flags. The installer of the pack shares them.
+func repoOptions(cmd *cobra.Command, packUrl *url.URL) ([]core.RepoOptFn, error) {
+	client, err := newHttpClient(cmd, packUrl)
+	if err != nil {
+		return nil, err
+	}
+	retry, err := retryPolicy(cmd)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := cmd.Flags().GetStringArray("rewrite")
+	if err != nil {
+		return nil, err
+	}
+	var rewrite core.RewriteRules
+	for _, r := range rules {
+		rule, err := core.ParseRewriteRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --rewrite: %w", err)
+		}
+		rewrite = append(rewrite, rule)
+	}
+	return []core.RepoOptFn{
+		core.WithHttpClient(client),
+		core.WithRetryPolicy(retry),
+		core.WithRewriteRules(rewrite),
+	}, nil
+}
+
+// newHttpClient returns the http client configured by the flags, the
+// credentials from the flags are sent to the host of packUrl only.
+func newHttpClient(cmd *cobra.Command, packUrl *url.URL) (*http.Client, error) {
+	flags := cmd.Flags()
+	var (
+		config = &core.HttpConfig{}
+		err    error
+	)
+	if config.ConnectTimeout, err = flags.GetDuration("connect-timeout"); err != nil {
+		return nil, err
+	}
+	if config.ReadTimeout, err = flags.GetDuration("read-timeout"); err != nil {
+		return nil, err
+	}
+	if config.Proxy, err = flags.GetString("proxy"); err != nil {
+		return nil, err
+	}
+	if config.CaFiles, err = flags.GetStringArray("ca-file"); err != nil {
+		return nil, err
+	}
+	if config.CertFile, err = flags.GetString("client-cert"); err != nil {
+		return nil, err
+	}
+	if config.KeyFile, err = flags.GetString("client-key"); err != nil {
+		return nil, err
+	}
+	if config.UserAgent, err = flags.GetString("user-agent"); err != nil {
+		return nil, err
+	}
+	if config.NetrcFile, err = flags.GetString("netrc-file"); err != nil {
+		return nil, err
+	}
+	netrc, err := flags.GetBool("netrc")
+	if err != nil {
+		return nil, err
+	}
+	if netrc && config.NetrcFile == "" {
+		config.NetrcFile = core.DefaultNetrcFile()
+	}
+	limitRate, err := parseRate(cmd.Flag("limit-rate").Value.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid --limit-rate: %w", err)
+	}
+	if limitRate > 0 {
+		config.RateLimit = core.NewRateLimiter(limitRate)
+	}
+
+	creds := core.Credentials{Host: packUrl.Host}
+	if creds.Token, err = flags.GetString("token"); err != nil {
+		return nil, err
+	}
+	if creds.Token == "" {
+		creds.Token = os.Getenv("PACKWIZ_TOKEN")
+	}
+	user, err := flags.GetString("user")
+	if err != nil {
+		return nil, err
+	}
+	if user != "" {
+		var ok bool
+		creds.Username, creds.Password, ok = strings.Cut(user, ":")
+		if !ok {
+			return nil, fmt.Errorf(`invalid --user format "<user>:<password>"`)
+		}
+	}
+	if packUrl.Host != "" && (creds.Token != "" || creds.Username != "") {
+		config.Credentials = append(config.Credentials, creds)
+	}
+	return config.NewClient()
+}
+
+// parseRate parses a number of bytes per second like "500K" or "1.5M", the
+// suffixes are powers of 1024.
+func parseRate(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	unit := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		unit = 1 << 10
+	case "M":
+		unit = 1 << 20
+	case "G":
+		unit = 1 << 30
+	}
+	if unit != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("not a rate: %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
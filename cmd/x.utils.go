@@ -10,6 +10,8 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/thatgurkangurk/packwiz-installer/core"
 )
 
 func exactArgs(n int) cobra.PositionalArgs {
@@ -32,3 +34,52 @@ func pluralize(word string, number int) string {
 	}
 	return word + "s"
 }
+
+func addJobsFlags(fs *pflag.FlagSet) {
+	fs.IntP("jobs", "j", core.DefaultJobs, "Number of files downloaded at once")
+	fs.Int("host-jobs", core.DefaultHostJobs, "Number of files downloaded at once from a single host, 0 for no limit")
+}
+
+// setJobs applies the download limits from the flags to inst.
+func setJobs(cmd *cobra.Command, inst *core.LocalInstaller) error {
+	jobs, err := cmd.Flags().GetInt("jobs")
+	if err != nil {
+		return err
+	}
+	hostJobs, err := cmd.Flags().GetInt("host-jobs")
+	if err != nil {
+		return err
+	}
+	if jobs < 1 || hostJobs < 0 {
+		return fmt.Errorf("invalid --jobs or --host-jobs value, must be at least 1 and 0")
+	}
+	inst.Jobs = jobs
+	inst.HostJobs = hostJobs
+	return nil
+}
+
+func addStoreFlags(fs *pflag.FlagSet) {
+	fs.Bool("store", false, "Share downloads with other installations through the store")
+	fs.String("store-dir", core.DefaultStoreDir(), "Directory of the store")
+	fs.String("link", string(core.LinkCopy), "How files from the store are installed: 'copy', 'hardlink' (read-only files shared with the store), or 'reflink'")
+	fs.String("proxy-cache", "", "URL of a 'packwiz-installer proxy' to download files from first")
+}
+
+// setStore applies the store and the caching proxy from the flags to inst,
+// if enabled.
+func setStore(cmd *cobra.Command, inst *core.LocalInstaller) error {
+	inst.ProxyCache = cmd.Flag("proxy-cache").Value.String()
+	if enabled, _ := cmd.Flags().GetBool("store"); !enabled {
+		return nil
+	}
+	dir := cmd.Flag("store-dir").Value.String()
+	if dir == "" {
+		return fmt.Errorf("no --store-dir given and no cache directory found")
+	}
+	link := core.LinkMode(cmd.Flag("link").Value.String())
+	if !link.IsValid() {
+		return fmt.Errorf("invalid --link value, must be 'copy', 'hardlink', or 'reflink'")
+	}
+	inst.Store = &core.Store{Dir: dir, Link: link}
+	return nil
+}
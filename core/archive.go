@@ -0,0 +1,399 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+type archiveKind string
+
+const (
+	archiveZip   archiveKind = "zip"
+	archiveTar   archiveKind = "tar"
+	archiveTarGz archiveKind = "tar.gz"
+)
+
+// getArchiveKind returns the kind of archive by its file name, or an empty
+// string if it is not an archive.
+func getArchiveKind(name string) archiveKind {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return archiveTarGz
+	case strings.HasSuffix(name, ".tar"):
+		return archiveTar
+	default:
+		return ""
+	}
+}
+
+// maxArchiveSize bounds the combined uncompressed size of the files of an
+// archive, and maxArchiveReadSize the size of a single file read into memory,
+// so that a malicious archive can neither fill the disk nor exhaust memory.
+var (
+	maxArchiveSize     int64 = 32 << 30
+	maxArchiveReadSize int64 = 64 << 20
+)
+
+// archive is a zip or tar archive whose files are read on demand, only its
+// list of files is held in memory.
+type archive struct {
+	r     io.ReaderAt
+	files map[string]archiveEntry // regular files by cleaned, slash separated path
+	close func() error
+}
+
+type archiveEntry struct {
+	zf     *zip.File // nil for tar entries
+	offset int64     // of the content of tar entries
+	size   int64
+}
+
+func (a *archive) open(name string) (io.ReadCloser, error) {
+	e, ok := a.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.zf != nil {
+		return e.zf.Open()
+	}
+	return io.NopCloser(io.NewSectionReader(a.r, e.offset, e.size)), nil
+}
+
+// readFile reads the file name into memory, see maxArchiveReadSize.
+func (a *archive) readFile(name string) ([]byte, error) {
+	if e, ok := a.files[name]; ok && e.size > maxArchiveReadSize {
+		return nil, fmt.Errorf("archive file too large to read: %s (%d bytes)", name, e.size)
+	}
+	rc, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// hash returns the hex encoded hash of the file name in hashFormat.
+func (a *archive) hash(name string, hashFormat string) (string, error) {
+	rc, err := a.open(name)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return hashReader(rc, hashFormat)
+}
+
+func (a *archive) Close() error {
+	if a.close == nil {
+		return nil
+	}
+	return a.close()
+}
+
+// archiveSource reads pack files from a zip or tar archive.
+type archiveSource struct {
+	archive *archive
+	root    string
+}
+
+// openArchiveSource loads the archive at u and returns the source along with
+// the name of pack.toml within it. The URL fragment may name the path of
+// pack.toml inside the archive, otherwise the least nested pack.toml is used.
+func openArchiveSource(ctx context.Context, u *url.URL, c *http.Client, retry *RetryPolicy, rewrite RewriteRules) (*archiveSource, string, error) {
+	a, err := loadArchive(ctx, u, c, retry, rewrite, getArchiveKind(u.Path))
+	if err != nil {
+		return nil, "", err
+	}
+	src, packFile, err := newArchiveSource(a, u)
+	if err != nil {
+		a.Close()
+		return nil, "", err
+	}
+	return src, packFile, nil
+}
+
+func newArchiveSource(a *archive, u *url.URL) (*archiveSource, string, error) {
+	packPath := path.Clean(strings.TrimPrefix(u.Fragment, "/"))
+	if u.Fragment == "" {
+		packPath = ""
+		for name := range a.files {
+			if path.Base(name) != "pack.toml" {
+				continue
+			}
+			if packPath == "" || strings.Count(name, "/") < strings.Count(packPath, "/") {
+				packPath = name
+			}
+		}
+		if packPath == "" {
+			return nil, "", fmt.Errorf("no pack.toml found in archive: %s", RedactUrl(u.String()))
+		}
+	}
+
+	return &archiveSource{
+		archive: a,
+		root:    path.Dir(packPath),
+	}, path.Base(packPath), nil
+}
+
+func (s *archiveSource) ReadFile(_ context.Context, name string) ([]byte, error) {
+	return s.archive.readFile(path.Join(s.root, name))
+}
+
+func (s *archiveSource) CopyFile(_ context.Context, name string, w io.Writer) error {
+	rc, err := s.archive.open(path.Join(s.root, name))
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// Close removes the temporary files of the archive.
+func (s *archiveSource) Close() error {
+	return s.archive.Close()
+}
+
+// loadArchive opens the archive at the file or http(s) URL u, see
+// fetchArchive and openArchive.
+func loadArchive(ctx context.Context, u *url.URL, c *http.Client, retry *RetryPolicy, rewrite RewriteRules, kind archiveKind) (*archive, error) {
+	f, err := fetchArchive(ctx, u, c, retry, rewrite)
+	if err != nil {
+		return nil, err
+	}
+	return openArchive(f, kind)
+}
+
+// archiveFile is an archive on disk, temporary ones are removed on Close.
+type archiveFile struct {
+	*os.File
+	temp bool
+}
+
+func (f *archiveFile) Close() error {
+	err := f.File.Close()
+	if f.temp {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// matchHash returns whether the whole archive matches hash.
+func (f *archiveFile) matchHash(hashFormat, hash string) (bool, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	sum, err := hashReader(io.NewSectionReader(f, 0, fi.Size()), hashFormat)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(sum, hash), nil
+}
+
+// fetchArchive returns the archive at the file or http(s) URL u. Remote
+// archives are downloaded to a temporary file rather than into memory.
+func fetchArchive(ctx context.Context, u *url.URL, c *http.Client, retry *RetryPolicy, rewrite RewriteRules) (*archiveFile, error) {
+	switch u.Scheme {
+	case "http", "https":
+		r := *u
+		r.Fragment = ""
+		tmp, err := os.CreateTemp("", "packwiz-installer-archive-*")
+		if err != nil {
+			return nil, err
+		}
+		f := &archiveFile{File: tmp, temp: true}
+		err = tryMirrors(ctx, rewrite.urls(r.String()), func(u string) error {
+			if err := tmp.Truncate(0); err != nil {
+				return err
+			}
+			if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			return httpCopy(ctx, c, retry, u, tmp)
+		})
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	case "file":
+		f, err := os.Open(fileUrlPath(u))
+		if err != nil {
+			return nil, err
+		}
+		return &archiveFile{File: f}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive url scheme: %q", u.Scheme)
+	}
+}
+
+// openArchive reads the list of files of f, which is closed along with the
+// returned archive.
+func openArchive(f *archiveFile, kind archiveKind) (*archive, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	a, err := readArchive(f, fi.Size(), kind)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	closeArchive := a.close
+	a.close = func() error {
+		if closeArchive != nil {
+			closeArchive()
+		}
+		return f.Close()
+	}
+	return a, nil
+}
+
+// readArchive reads the list of files of the archive in r. Compressed tar
+// archives are decompressed to a temporary file first, which is removed when
+// the archive is closed.
+func readArchive(r io.ReaderAt, size int64, kind archiveKind) (*archive, error) {
+	switch kind {
+	case archiveZip:
+		return readZip(r, size)
+	case archiveTar:
+		return readTar(r, size)
+	case archiveTarGz:
+		return readTarGz(r, size)
+	default:
+		return nil, fmt.Errorf("unsupported archive kind: %q", kind)
+	}
+}
+
+func readZip(r io.ReaderAt, size int64) (*archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &archive{r: r, files: make(map[string]archiveEntry, len(zr.File))}
+	var total uint64
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		// the zip reader fails on files longer than their header says
+		total += f.UncompressedSize64
+		if f.UncompressedSize64 > uint64(maxArchiveSize) || total > uint64(maxArchiveSize) {
+			return nil, fmt.Errorf("archive too large, more than %d bytes uncompressed", maxArchiveSize)
+		}
+		a.files[archivePath(f.Name)] = archiveEntry{zf: f, size: int64(f.UncompressedSize64)}
+	}
+	return a, nil
+}
+
+// readTar indexes the regular files of the tar archive in r by the offset of
+// their content, so that they can be read directly later on.
+func readTar(r io.ReaderAt, size int64) (*archive, error) {
+	a := &archive{r: r, files: make(map[string]archiveEntry)}
+	cr := &countingReader{r: io.NewSectionReader(r, 0, size)}
+	tr := tar.NewReader(cr)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		// sparse files are stored in pieces, they can not be read directly
+		if isSparse(hdr) || hdr.Size > size-cr.n {
+			return nil, fmt.Errorf("unsupported archive file: %s", hdr.Name)
+		}
+		total += hdr.Size
+		if total > maxArchiveSize {
+			return nil, fmt.Errorf("archive too large, more than %d bytes uncompressed", maxArchiveSize)
+		}
+		// the header was read, cr is at the start of the content
+		a.files[archivePath(hdr.Name)] = archiveEntry{offset: cr.n, size: hdr.Size}
+	}
+	return a, nil
+}
+
+func isSparse(hdr *tar.Header) bool {
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+func readTarGz(r io.ReaderAt, size int64) (*archive, error) {
+	gz, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tmp, err := os.CreateTemp("", "packwiz-installer-archive-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	remove := func() error {
+		err := tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	n, err := io.Copy(tmp, io.LimitReader(gz, maxArchiveSize+1))
+	if err == nil && n > maxArchiveSize {
+		err = fmt.Errorf("archive too large, more than %d bytes uncompressed", maxArchiveSize)
+	}
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	a, err := readTar(tmp, n)
+	if err != nil {
+		remove()
+		return nil, err
+	}
+	a.close = remove
+	return a, nil
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func archivePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
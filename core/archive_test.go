@@ -0,0 +1,141 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestArchive returns a zip archive of files held in memory.
+func newTestArchive(t *testing.T, files map[string][]byte) *archive {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := readArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), archiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func newTestTar(t *testing.T, files map[string]string, gz bool) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	var w io.Writer = buf
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(buf)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	for name, content := range files {
+		// long names are stored in an extra pax header before the file
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Format: tar.FormatPAX}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReadArchive(t *testing.T) {
+	files := map[string]string{
+		"pack/pack.toml":    "toml",
+		"pack/config/a.txt": "a",
+		"pack/" + string(bytes.Repeat([]byte("x"), 200)): "long name",
+		"pack/empty.txt": "",
+	}
+	for _, kind := range []archiveKind{archiveTar, archiveTarGz} {
+		t.Run(string(kind), func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "pack."+string(kind))
+			if err := os.WriteFile(p, newTestTar(t, files, kind == archiveTarGz), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			a, err := loadArchive(context.Background(), must(ParsePackUrl(p)), nil, nil, nil, kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Close()
+			if len(a.files) != len(files) {
+				t.Errorf("got %d files, want %d", len(a.files), len(files))
+			}
+			for name, content := range files {
+				if data, err := a.readFile(name); err != nil || string(data) != content {
+					t.Errorf("readFile(%s) = %q, %v, want %q", name, data, err, content)
+				}
+			}
+		})
+	}
+}
+
+func TestReadArchive_Limits(t *testing.T) {
+	defer func(size, read int64) {
+		maxArchiveSize, maxArchiveReadSize = size, read
+	}(maxArchiveSize, maxArchiveReadSize)
+	maxArchiveSize, maxArchiveReadSize = 1024, 16
+
+	// a zip claiming more than it holds is rejected up front
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{Name: "bomb", Method: zip.Store, UncompressedSize64: 1 << 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("x"))
+	zw.Close()
+	if _, err := readArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), archiveZip); err == nil {
+		t.Error("readArchive() accepted a zip larger than maxArchiveSize")
+	}
+
+	data := newTestTar(t, map[string]string{"a": string(make([]byte, 2048))}, true)
+	if _, err := readArchive(bytes.NewReader(data), int64(len(data)), archiveTarGz); err == nil {
+		t.Error("readArchive() accepted a tar.gz larger than maxArchiveSize")
+	}
+
+	a := newTestArchive(t, map[string][]byte{"small": []byte("ok"), "large": make([]byte, 32)})
+	if _, err := a.readFile("small"); err != nil {
+		t.Errorf("readFile(small) error = %v", err)
+	}
+	if _, err := a.readFile("large"); err == nil {
+		t.Error("readFile() read a file larger than maxArchiveReadSize")
+	}
+	// larger files are still streamed
+	src := &archiveSource{archive: a}
+	if err := src.CopyFile(context.Background(), "large", io.Discard); err != nil {
+		t.Errorf("CopyFile(large) error = %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Credentials authenticate the requests to a single host.
+type Credentials struct {
+	Host     string // host, with a port only if it must match as well
+	Token    string // bearer token, used instead of Username and Password if set
+	Username string
+	Password string
+}
+
+func (c *Credentials) matches(u *url.URL) bool {
+	return strings.EqualFold(c.Host, u.Host) || strings.EqualFold(c.Host, u.Hostname())
+}
+
+// authorization returns the value of the Authorization header.
+func (c *Credentials) authorization() string {
+	if c.Token != "" {
+		return "Bearer " + c.Token
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password))
+}
+
+// authTransport authenticates requests to the hosts it has credentials for,
+// requests to any other host, like mod downloads, are sent unchanged.
+type authTransport struct {
+	base        http.RoundTripper
+	credentials []Credentials
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// credentials from the url take precedence
+	if req.Header.Get("Authorization") != "" {
+		return t.base.RoundTrip(req)
+	}
+	for _, c := range t.credentials {
+		if c.matches(req.URL) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", c.authorization())
+			break
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// DefaultNetrcFile returns the netrc file named by $NETRC, or the one in the
+// home directory.
+func DefaultNetrcFile() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "_netrc")
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// readNetrc returns the machine entries of the netrc file at p, nothing if
+// it does not exist. The default entry is ignored, as it would send the
+// credentials to every host.
+func readNetrc(p string) ([]Credentials, error) {
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		creds   []Credentials
+		current = -1 // index of the machine being read, -1 for default
+	)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) > 0 && fields[0] == "macdef" {
+			// macro definitions run until an empty line
+			for s.Scan() && strings.TrimSpace(s.Text()) != "" {
+			}
+			continue
+		}
+		for i := 0; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "#") {
+				break
+			}
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+			switch fields[i] {
+			case "machine":
+				creds = append(creds, Credentials{Host: value})
+				current = len(creds) - 1
+				i++
+			case "default":
+				current = -1
+			case "login":
+				if current != -1 {
+					creds[current].Username = value
+				}
+				i++
+			case "password":
+				if current != -1 {
+					creds[current].Password = value
+				}
+				i++
+			case "account":
+				i++
+			default:
+				return nil, fmt.Errorf("parse netrc: unexpected %q", fields[i])
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
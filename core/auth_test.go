@@ -0,0 +1,84 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func Test_readNetrc(t *testing.T) {
+	p := filepath.Join(t.TempDir(), ".netrc")
+	writeTestFile(t, p, `machine packs.example.com login alice password secret
+# a comment
+machine other.example.com
+  login bob
+  password hunter2
+
+macdef init
+cd /pub
+machine ignored.example.com
+
+default login anonymous password guest
+`)
+	creds, err := readNetrc(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Credentials{
+		{Host: "packs.example.com", Username: "alice", Password: "secret"},
+		{Host: "other.example.com", Username: "bob", Password: "hunter2"},
+	}
+	if !slices.Equal(creds, want) {
+		t.Errorf("readNetrc() = %+v, want %+v", creds, want)
+	}
+
+	if creds, err := readNetrc(filepath.Join(t.TempDir(), "missing")); err != nil || creds != nil {
+		t.Errorf("readNetrc() of a missing file = %v, %v", creds, err)
+	}
+}
+
+func TestHttpConfig_Credentials(t *testing.T) {
+	var gotAuth = map[string]string{}
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotAuth[name] = r.Header.Get("Authorization")
+		})
+	}
+	pack := httptest.NewServer(handler("pack"))
+	defer pack.Close()
+	cdn := httptest.NewServer(handler("cdn"))
+	defer cdn.Close()
+
+	packUrl, err := url.Parse(pack.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := (&HttpConfig{
+		Credentials: []Credentials{{Host: packUrl.Host, Token: "token"}},
+	}).NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range []string{pack.URL, cdn.URL} {
+		if _, err := httpGetBytes(context.Background(), c, nil, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gotAuth["pack"] != "Bearer token" {
+		t.Errorf("pack Authorization = %q, want the token", gotAuth["pack"])
+	}
+	if gotAuth["cdn"] != "" {
+		t.Errorf("credentials were sent to another host: %q", gotAuth["cdn"])
+	}
+}
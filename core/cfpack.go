@@ -0,0 +1,310 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const curseManifestFile = "manifest.json"
+
+// curseLoaders lists the packwiz version keys of mod loaders curseforge
+// knows, their curseforge ids are "<key>-<version>".
+var curseLoaders = []string{"forge", "neoforge", "fabric", "quilt"}
+
+type CurseManifest struct {
+	Minecraft       CurseManifestMinecraft `json:"minecraft"`
+	ManifestType    string                 `json:"manifestType"`
+	ManifestVersion int                    `json:"manifestVersion"`
+	Name            string                 `json:"name"`
+	Version         string                 `json:"version"`
+	Author          string                 `json:"author"`
+	Files           []CurseManifestFile    `json:"files"`
+	Overrides       string                 `json:"overrides"`
+}
+
+type CurseManifestMinecraft struct {
+	Version    string           `json:"version"`
+	ModLoaders []CurseModLoader `json:"modLoaders"`
+}
+
+type CurseModLoader struct {
+	ID      string `json:"id"`
+	Primary bool   `json:"primary"`
+}
+
+type CurseManifestFile struct {
+	ProjectID int  `json:"projectID"`
+	FileID    int  `json:"fileID"`
+	Required  bool `json:"required"`
+}
+
+// isCurseModpack returns whether an archive is a curseforge modpack export
+// rather than a packwiz repository.
+func isCurseModpack(a *archive) bool {
+	if _, ok := a.files[curseManifestFile]; !ok {
+		return false
+	}
+	for name := range a.files {
+		if path.Base(name) == "pack.toml" {
+			return false
+		}
+	}
+	return true
+}
+
+// loadCurseModpack returns the pack of the curseforge modpack export a, read
+// from f and verified against hash if it is not empty. The pack owns a.
+func loadCurseModpack(ctx context.Context, f *archiveFile, a *archive, hashFormat, hash string, repo *Repository) (*Pack, error) {
+	if hash != "" {
+		valid, err := f.matchHash(hashFormat, hash)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			return nil, fmt.Errorf("modpack hash mismatched: %s", RedactUrl(repo.Url.String()))
+		}
+	}
+	p, err := curseModpackToPack(ctx, a, DefaultCurseClient.WithHttpClient(repo.httpClient).WithRetryPolicy(repo.retry))
+	if err != nil {
+		return nil, err
+	}
+	p.httpClient = repo.httpClient
+	p.retry = repo.retry
+	p.rewrite = repo.rewrite
+	return p, nil
+}
+
+// curseModpackToPack converts a curseforge modpack export into a Pack.
+//
+// The manifest carries no hashes, so the files are looked up through the
+// curseforge api and the hashes it reports are used for verification.
+// Files without any hash are rejected rather than installed unverified.
+func curseModpackToPack(ctx context.Context, a *archive, c *CurseClient) (*Pack, error) {
+	data, err := a.readFile(curseManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest = new(CurseManifest)
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", curseManifestFile, err)
+	}
+	if manifest.ManifestType != "minecraftModpack" {
+		return nil, fmt.Errorf("unsupported curseforge manifest type: %q", manifest.ManifestType)
+	}
+
+	var pack = &Pack{
+		Name:     manifest.Name,
+		Author:   manifest.Author,
+		Version:  manifest.Version,
+		Versions: map[string]string{"minecraft": manifest.Minecraft.Version},
+		source:   &archiveSource{archive: a},
+	}
+	for _, l := range manifest.Minecraft.ModLoaders {
+		// loader ids look like "forge-47.2.0"
+		loader, v, ok := strings.Cut(l.ID, "-")
+		if ok {
+			pack.Versions[loader] = v
+		}
+	}
+
+	var (
+		fileIds []int
+		modIds  []int
+	)
+	for _, f := range manifest.Files {
+		fileIds = append(fileIds, f.FileID)
+		modIds = append(modIds, f.ProjectID)
+	}
+
+	var mods = make([]*Mod, 0, len(fileIds))
+	if len(fileIds) > 0 {
+		cfFiles, err := c.GetFiles(ctx, fileIds)
+		if err != nil {
+			return nil, err
+		}
+		cfMods, err := c.GetMods(ctx, modIds)
+		if err != nil {
+			return nil, err
+		}
+		// kept for the installer, so it does not look the files up again
+		pack.curseFiles = make(map[int]*CurseFile, len(cfFiles))
+		for _, f := range cfFiles {
+			pack.curseFiles[f.ID] = &f
+		}
+
+		for _, f := range manifest.Files {
+			m, err := curseFileToMod(f, cfFiles, cfMods)
+			if err != nil {
+				return nil, err
+			}
+			// files that are not required are disabled in the curseforge launcher
+			if !f.Required {
+				m.Option = &ModOption{Default: false}
+			}
+			mods = append(mods, m)
+		}
+	}
+
+	overrides := manifest.Overrides
+	if overrides == "" {
+		overrides = "overrides"
+	}
+	prefix := archivePath(overrides) + "/"
+	var names []string
+	for name := range a.files {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		hash, err := a.hash(name, "sha256")
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, &Mod{
+			Path:       strings.TrimPrefix(name, prefix),
+			Hash:       hash,
+			HashFormat: "sha256",
+			Side:       Side_Both,
+			Downloads: &Download{
+				Type: DL_Source,
+				Data: name,
+			},
+			Size: a.files[name].size,
+		})
+	}
+
+	pack.Mods = mods
+	return pack, nil
+}
+
+func curseFileToMod(f CurseManifestFile, cfFiles []CurseFile, cfMods []CurseMod) (*Mod, error) {
+	cfData := &CurseforgeData{ProjectID: f.ProjectID, FileID: f.FileID}
+
+	i := slices.IndexFunc(cfFiles, func(cf CurseFile) bool {
+		return cf.ID == f.FileID
+	})
+	if i == -1 {
+		return nil, fmt.Errorf("curseforge file not found: %s", cfData)
+	}
+	file := cfFiles[i]
+	if file.ModID != f.ProjectID {
+		return nil, fmt.Errorf("curseforge file %d does not belong to project %d", f.FileID, f.ProjectID)
+	}
+	name := file.FileName
+	if name == "" || !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid curseforge file name %q: %s", name, cfData)
+	}
+
+	hashFmt, hash := file.Hash()
+	if hash == "" {
+		return nil, fmt.Errorf("curseforge provides no hash to verify %s (%s)", name, cfData)
+	}
+
+	dir, modName := "mods", ""
+	if j := slices.IndexFunc(cfMods, func(m CurseMod) bool { return m.ID == f.ProjectID }); j != -1 {
+		modName = cfMods[j].Name
+		switch cfMods[j].ClassID {
+		case cfClassResourcePacks:
+			dir = "resourcepacks"
+		case cfClassShaderPacks:
+			dir = "shaderpacks"
+		}
+	}
+
+	return &Mod{
+		Path:       path.Join(dir, name),
+		Name:       modName,
+		Hash:       hash,
+		HashFormat: hashFmt,
+		Side:       Side_Both,
+		Downloads: &Download{
+			Type: DL_Curseforge,
+			Data: cfData.String(),
+		},
+		Size: file.FileLength,
+	}, nil
+}
+
+// ExportCurseforge writes the pack installed by inst as a curseforge modpack.
+//
+// Curseforge mods become entries of the manifest, every other file is
+// copied into the overrides folder from the installed instance.
+func ExportCurseforge(w io.Writer, inst *LocalInstaller) error {
+	p := inst.Pack
+	var manifest = &CurseManifest{
+		Minecraft: CurseManifestMinecraft{
+			Version:    p.Versions["minecraft"],
+			ModLoaders: []CurseModLoader{},
+		},
+		ManifestType:    "minecraftModpack",
+		ManifestVersion: 1,
+		Name:            p.Name,
+		Version:         p.Version,
+		Author:          p.Author,
+		Files:           []CurseManifestFile{},
+		Overrides:       "overrides",
+	}
+	if manifest.Minecraft.Version == "" {
+		return fmt.Errorf("curseforge export requires the minecraft version of the pack")
+	}
+	for _, loader := range curseLoaders {
+		if v := p.Versions[loader]; v != "" {
+			manifest.Minecraft.ModLoaders = append(manifest.Minecraft.ModLoaders, CurseModLoader{
+				ID:      loader + "-" + v,
+				Primary: len(manifest.Minecraft.ModLoaders) == 0,
+			})
+		}
+	}
+
+	var overrides []*Mod
+	for _, m := range p.Mods {
+		if m.Downloads == nil || m.Downloads.Type != DL_Curseforge {
+			overrides = append(overrides, m)
+			continue
+		}
+
+		cfData, err := ParseCfData(m.Downloads.Data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.Path, err)
+		}
+		manifest.Files = append(manifest.Files, CurseManifestFile{
+			ProjectID: cfData.ProjectID,
+			FileID:    cfData.FileID,
+			Required:  !m.IsOptional() || m.Option.Default,
+		})
+	}
+
+	zw := zip.NewWriter(w)
+	mw, err := zw.Create(curseManifestFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	for _, m := range overrides {
+		name := path.Join(manifest.Overrides, m.Path)
+		if err := addZipFile(zw, name, filepath.Join(inst.BaseDir, m.Path)); err != nil {
+			return fmt.Errorf("add %s: %w", m.Path, err)
+		}
+	}
+	return zw.Close()
+}
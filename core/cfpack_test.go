@@ -0,0 +1,284 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestCurseApi serves files and mods like the curseforge api, and the
+// files themselves under /files/ with the content "jar <file name>".
+func newTestCurseApi(t *testing.T, files []CurseFile, mods []CurseMod) *CurseClient {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			FileIds []int `json:"fileIds"`
+			ModIds  []int `json:"modIds"`
+		}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/mods/files":
+			var res cfFilesRes
+			for _, f := range files {
+				if slices.Contains(body.FileIds, f.ID) {
+					res.Data = append(res.Data, f)
+				}
+			}
+			json.NewEncoder(w).Encode(res)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/mods":
+			var res cfModsRes
+			for _, m := range mods {
+				if slices.Contains(body.ModIds, m.ID) {
+					res.Data = append(res.Data, m)
+				}
+			}
+			json.NewEncoder(w).Encode(res)
+		case strings.HasSuffix(r.URL.Path, "/download-url"):
+			for _, f := range files {
+				if r.URL.Path == fmt.Sprintf("/v1/mods/%d/files/%d/download-url", f.ModID, f.ID) {
+					json.NewEncoder(w).Encode(cfDownloadUrlRes{Data: srv.URL + "/files/" + f.FileName})
+					return
+				}
+			}
+			http.NotFound(w, r)
+		case strings.HasPrefix(r.URL.Path, "/files/"):
+			w.Write([]byte("jar " + strings.TrimPrefix(r.URL.Path, "/files/")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client := NewCurseClient("test")
+	client.httpClient = client.httpClient.BaseURL(srv.URL)
+	return client
+}
+
+func newTestCurseFile(id, modId int, name string) CurseFile {
+	return CurseFile{
+		ID:         id,
+		ModID:      modId,
+		FileName:   name,
+		Hashes:     []CurseFileHash{{Value: fmt.Sprintf("%x", sha1.Sum([]byte("jar "+name))), Algo: cfAlgoSha1}},
+		FileLength: int64(len("jar " + name)),
+	}
+}
+
+func newTestCurseModpack(t *testing.T, files []CurseManifestFile, overrides map[string][]byte) *archive {
+	t.Helper()
+	manifest, err := json.Marshal(&CurseManifest{
+		Minecraft: CurseManifestMinecraft{
+			Version:    "1.20.1",
+			ModLoaders: []CurseModLoader{{ID: "forge-47.2.0", Primary: true}},
+		},
+		ManifestType:    "minecraftModpack",
+		ManifestVersion: 1,
+		Name:            "test",
+		Version:         "1.0.0",
+		Files:           files,
+		Overrides:       "overrides",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string][]byte{curseManifestFile: manifest}
+	for name, data := range overrides {
+		entries["overrides/"+name] = data
+	}
+	return newTestArchive(t, entries)
+}
+
+func Test_curseModpackToPack(t *testing.T) {
+	client := newTestCurseApi(t,
+		[]CurseFile{newTestCurseFile(100, 1, "mod.jar"), newTestCurseFile(200, 2, "pack.zip")},
+		[]CurseMod{{ID: 1, Name: "Mod"}, {ID: 2, Name: "Pack", ClassID: cfClassResourcePacks}},
+	)
+	defer func(c *CurseClient) { DefaultCurseClient = c }(DefaultCurseClient)
+	DefaultCurseClient = client
+
+	a := newTestCurseModpack(t, []CurseManifestFile{
+		{ProjectID: 1, FileID: 100, Required: true},
+		{ProjectID: 2, FileID: 200, Required: false},
+	}, map[string][]byte{"config/a.toml": []byte("override")})
+	pack, err := curseModpackToPack(context.Background(), a, client)
+	if err != nil {
+		t.Fatalf("curseModpackToPack() error = %v", err)
+	}
+	if pack.Name != "test" || pack.Versions["minecraft"] != "1.20.1" || pack.Versions["forge"] != "47.2.0" {
+		t.Errorf("pack = %s %v", pack.Name, pack.Versions)
+	}
+
+	type want struct {
+		name     string
+		dlType   DLType
+		optional bool
+	}
+	wants := map[string]want{
+		"mods/mod.jar":           {"Mod", DL_Curseforge, false},
+		"resourcepacks/pack.zip": {"Pack", DL_Curseforge, true},
+		"config/a.toml":          {"", DL_Source, false},
+	}
+	if len(pack.Mods) != len(wants) {
+		t.Fatalf("got %d mods, want %d", len(pack.Mods), len(wants))
+	}
+	for _, m := range pack.Mods {
+		w, ok := wants[m.Path]
+		if !ok {
+			t.Errorf("unexpected mod %s", m.Path)
+			continue
+		}
+		if m.Name != w.name || m.Downloads.Type != w.dlType || m.IsOptional() != w.optional {
+			t.Errorf("%s = %q %v optional %v, want %q %v optional %v",
+				m.Path, m.Name, m.Downloads.Type, m.IsOptional(), w.name, w.dlType, w.optional)
+		}
+	}
+
+	// files that are not required are skipped, overrides come from the zip
+	inst, err := NewLocalInstaller(pack, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	for name, content := range map[string]string{"mods/mod.jar": "jar mod.jar", "config/a.toml": "override"} {
+		data, err := os.ReadFile(filepath.Join(inst.BaseDir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("installed %s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(inst.BaseDir, "resourcepacks", "pack.zip")); !os.IsNotExist(err) {
+		t.Errorf("optional file installed, stat error = %v", err)
+	}
+}
+
+func Test_curseModpackToPack_Rejects(t *testing.T) {
+	hashless := newTestCurseFile(100, 1, "mod.jar")
+	hashless.Hashes = []CurseFileHash{{Value: "", Algo: cfAlgoSha1}}
+	tests := []struct {
+		name    string
+		file    CurseFile
+		wantErr bool
+	}{
+		{"valid", newTestCurseFile(100, 1, "mod.jar"), false},
+		{"hashless", hashless, true},
+		{"other project", newTestCurseFile(100, 2, "mod.jar"), true},
+		{"parent dir", newTestCurseFile(100, 1, "../mod.jar"), true},
+		{"sub dir", newTestCurseFile(100, 1, "mods/mod.jar"), true},
+		{"backslash", newTestCurseFile(100, 1, `..\mod.jar`), true},
+		{"empty name", newTestCurseFile(100, 1, ""), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestCurseApi(t, []CurseFile{tt.file}, []CurseMod{{ID: 1, Name: "Mod"}})
+			a := newTestCurseModpack(t, []CurseManifestFile{{ProjectID: 1, FileID: 100, Required: true}}, nil)
+			if _, err := curseModpackToPack(context.Background(), a, client); (err != nil) != tt.wantErr {
+				t.Errorf("curseModpackToPack() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExportCurseforge(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "mods", "mod.jar"), "jar mod.jar")
+	writeTestFile(t, filepath.Join(dir, "mods", "other.jar"), "other")
+	writeTestFile(t, filepath.Join(dir, "config", "a.toml"), "config")
+
+	inst, err := NewLocalInstaller(&Pack{
+		Name:     "test",
+		Version:  "1.0.0",
+		Versions: map[string]string{"minecraft": "1.20.1", "fabric": "0.15.0", "quilt": "0.23.0"},
+		Mods: []*Mod{
+			{
+				Path:      "mods/mod.jar",
+				Downloads: &Download{Type: DL_Curseforge, Data: (&CurseforgeData{ProjectID: 1, FileID: 100}).String()},
+			},
+			{
+				Path:      "mods/other.jar",
+				Downloads: &Download{Type: DL_Url, Data: "https://cdn.modrinth.com/other.jar"},
+			},
+			{
+				Path:      "config/a.toml",
+				Downloads: &Download{Type: DL_Source, Data: "config/a.toml"},
+			},
+		},
+	}, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := ExportCurseforge(buf, inst); err != nil {
+		t.Fatalf("ExportCurseforge() error = %v", err)
+	}
+	a, err := readArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), archiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := a.readFile(curseManifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest CurseManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Minecraft.Version != "1.20.1" {
+		t.Errorf("minecraft.version = %q, want 1.20.1", manifest.Minecraft.Version)
+	}
+	wantLoaders := []CurseModLoader{{ID: "fabric-0.15.0", Primary: true}, {ID: "quilt-0.23.0"}}
+	if !slices.Equal(manifest.Minecraft.ModLoaders, wantLoaders) {
+		t.Errorf("modLoaders = %v, want %v", manifest.Minecraft.ModLoaders, wantLoaders)
+	}
+
+	client := newTestCurseApi(t, []CurseFile{newTestCurseFile(100, 1, "mod.jar")}, []CurseMod{{ID: 1, Name: "Mod"}})
+	pack, err := curseModpackToPack(context.Background(), a, client)
+	if err != nil {
+		t.Fatalf("curseModpackToPack() error = %v", err)
+	}
+	if pack.Name != "test" || pack.Versions["minecraft"] != "1.20.1" || pack.Versions["fabric"] != "0.15.0" {
+		t.Errorf("pack = %s %v", pack.Name, pack.Versions)
+	}
+	wants := map[string]DLType{
+		"mods/mod.jar":   DL_Curseforge,
+		"mods/other.jar": DL_Source,
+		"config/a.toml":  DL_Source,
+	}
+	if len(pack.Mods) != len(wants) {
+		t.Fatalf("got %d mods, want %d", len(pack.Mods), len(wants))
+	}
+	for _, m := range pack.Mods {
+		if dlType, ok := wants[m.Path]; !ok || m.Downloads.Type != dlType {
+			t.Errorf("%s downloads from %v, want %v", m.Path, m.Downloads.Type, dlType)
+		}
+	}
+	// everything else is copied from the instance
+	for name, content := range map[string]string{"mods/other.jar": "other", "config/a.toml": "config"} {
+		if data, err := a.readFile("overrides/" + name); err != nil || string(data) != content {
+			t.Errorf("override %s = %q, %v, want %q", name, data, err, content)
+		}
+	}
+}
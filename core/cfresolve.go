@@ -0,0 +1,174 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// cfBatchSize is the number of files looked up in a single curseforge request
+const cfBatchSize = 500
+
+// resolveCurseFiles looks up the curseforge files of mods in batches, before
+// any of them is downloaded, instead of asking for every download url on its
+// own. The files curseforge reports are checked against the pack, so that a
+// mismatching file name, size or hash fails the install before anything is
+// written.
+//
+// Files are cached in .pw-install, as curseforge files never change. Their
+// download urls might, so downloads failing from a cached url ask curseforge
+// for the current one, see updateCurseUrl. Mods
+// that do not need a download, because the store has them or they are
+// preserved and exist already, are not looked up.
+func (i *LocalInstaller) resolveCurseFiles(ctx context.Context, mods []*Mod) error {
+	var cached map[int]*CurseFile
+	if err := i.restoreCache("curseforge", &cached); err != nil {
+		return fmt.Errorf("read cache: %w", err)
+	}
+	files := make(map[int]*CurseFile)
+	for id, f := range i.Pack.curseFiles {
+		files[id] = f
+	}
+
+	var (
+		resolve []*Mod
+		missing []int
+	)
+	for _, m := range mods {
+		if m.Downloads.Type != DL_Curseforge || !i.needsDownload(m) {
+			continue
+		}
+		cfData, err := ParseCfData(m.Downloads.Data)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, m.Path)
+		}
+		resolve = append(resolve, m)
+		if files[cfData.FileID] != nil {
+			continue
+		}
+		if f := cached[cfData.FileID]; f != nil {
+			files[cfData.FileID] = f
+			continue
+		}
+		missing = append(missing, cfData.FileID)
+	}
+	if len(resolve) == 0 {
+		return nil
+	}
+
+	client := i.curseClient()
+	if len(missing) > 0 && client.apiKey == "" {
+		// left to the downloads, which may not need the api with a proxy
+		return nil
+	}
+	for start := 0; start < len(missing); start += cfBatchSize {
+		batch, err := client.GetFiles(ctx, missing[start:min(start+cfBatchSize, len(missing))])
+		if err != nil {
+			return fmt.Errorf("resolve curseforge files: %w", err)
+		}
+		for _, f := range batch {
+			files[f.ID] = &f
+		}
+	}
+
+	var errs []error
+	for _, m := range resolve {
+		cfData, _ := ParseCfData(m.Downloads.Data)
+		f := files[cfData.FileID]
+		if err := checkCurseFile(m, cfData, f); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if m.Size == 0 {
+			m.Size = f.FileLength
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	i.curseFiles = files
+	return i.saveCache("curseforge", files)
+}
+
+// needsDownload returns whether installing m downloads it.
+func (i *LocalInstaller) needsDownload(m *Mod) bool {
+	if m.Preserve {
+		_, err := os.Stat(filepath.Join(i.BaseDir, m.Path))
+		return err != nil
+	}
+	if i.Store != nil {
+		if obj, err := i.Store.objectPath(m.HashFormat, m.Hash); err == nil {
+			if _, err := os.Stat(obj); err == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkCurseFile returns an error if f, as reported by curseforge, is not the
+// file m expects.
+func checkCurseFile(m *Mod, cfData *CurseforgeData, f *CurseFile) error {
+	if f == nil {
+		return fmt.Errorf("curseforge file not found: %s (%s)", cfData, m.Path)
+	}
+	if f.ModID != cfData.ProjectID {
+		return fmt.Errorf("curseforge file %d does not belong to project %d: %s", cfData.FileID, cfData.ProjectID, m.Path)
+	}
+	name := m.fileName
+	if name == "" {
+		name = path.Base(m.Path)
+	}
+	if f.FileName != name {
+		return fmt.Errorf("curseforge file name %q does not match %q: %s", f.FileName, name, cfData)
+	}
+	if m.Size != 0 && f.FileLength != 0 && m.Size != f.FileLength {
+		return fmt.Errorf("curseforge file size %d does not match %d: %s", f.FileLength, m.Size, m.Path)
+	}
+	for _, h := range f.Hashes {
+		var format string
+		switch h.Algo {
+		case cfAlgoSha1:
+			format = "sha1"
+		case cfAlgoMd5:
+			format = "md5"
+		}
+		if h.Value != "" && strings.EqualFold(format, m.HashFormat) && !strings.EqualFold(h.Value, m.Hash) {
+			return fmt.Errorf("curseforge %s hash %s does not match %s: %s", format, h.Value, m.Hash, m.Path)
+		}
+	}
+	return nil
+}
+
+// curseUrl returns the resolved download url of the curseforge file id, or
+// an empty string if it is unknown.
+func (i *LocalInstaller) curseUrl(id int) string {
+	i.curseMu.Lock()
+	defer i.curseMu.Unlock()
+	if f := i.curseFiles[id]; f != nil {
+		return f.DownloadUrl
+	}
+	return ""
+}
+
+// updateCurseUrl replaces the outdated download url of the curseforge file
+// id, the cache is saved once the install finished.
+func (i *LocalInstaller) updateCurseUrl(id int, u string) {
+	i.curseMu.Lock()
+	defer i.curseMu.Unlock()
+	f := *i.curseFiles[id]
+	f.DownloadUrl = u
+	i.curseFiles[id] = &f
+	i.curseUpdated = true
+}
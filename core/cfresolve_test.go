@@ -0,0 +1,163 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLocalInstaller_ResolveCurseFiles(t *testing.T) {
+	var batches, urlReqs, downloads atomic.Int32
+	// the path files are served from, which curseforge may move
+	var cdn atomic.Value
+	cdn.Store("/files/")
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/mods/files":
+			batches.Add(1)
+			var body struct {
+				FileIds []int `json:"fileIds"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var res cfFilesRes
+			for _, id := range body.FileIds {
+				name := strconv.Itoa(id) + ".jar"
+				res.Data = append(res.Data, CurseFile{
+					ID:          id,
+					ModID:       id / 10,
+					FileName:    name,
+					Hashes:      []CurseFileHash{{Value: sha1Hex([]byte("jar " + name)), Algo: cfAlgoSha1}},
+					FileLength:  int64(len("jar " + name)),
+					DownloadUrl: srv.URL + cdn.Load().(string) + name,
+				})
+			}
+			json.NewEncoder(w).Encode(res)
+		case strings.HasSuffix(r.URL.Path, "/download-url"):
+			urlReqs.Add(1)
+			// /v1/mods/{modId}/files/{fileId}/download-url
+			id := strings.Split(r.URL.Path, "/")[5]
+			json.NewEncoder(w).Encode(cfDownloadUrlRes{Data: srv.URL + cdn.Load().(string) + id + ".jar"})
+		case strings.HasPrefix(r.URL.Path, cdn.Load().(string)):
+			downloads.Add(1)
+			w.Write([]byte("jar " + strings.TrimPrefix(r.URL.Path, cdn.Load().(string))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewCurseClient("test")
+	client.httpClient = client.httpClient.BaseURL(srv.URL)
+	client.retry = nil
+	defer func(c *CurseClient) { DefaultCurseClient = c }(DefaultCurseClient)
+	DefaultCurseClient = client
+
+	newMod := func(projectId, fileId int, name string) *Mod {
+		return &Mod{
+			Path:       "mods/" + name,
+			Hash:       sha1Hex([]byte("jar " + strconv.Itoa(fileId) + ".jar")),
+			HashFormat: "sha1",
+			Side:       Side_Both,
+			Downloads: &Download{
+				Type: DL_Curseforge,
+				Data: (&CurseforgeData{ProjectID: projectId, FileID: fileId}).String(),
+			},
+		}
+	}
+	install := func(dir string, mods ...*Mod) error {
+		inst, err := NewLocalInstaller(&Pack{Name: "test", Mods: mods}, dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst.Retry = nil
+		_, err = inst.Install(context.Background())
+		return err
+	}
+
+	// every file is resolved by a single request
+	dir := t.TempDir()
+	var mods []*Mod
+	for id := 10; id < 20; id++ {
+		mods = append(mods, newMod(1, id, strconv.Itoa(id)+".jar"))
+	}
+	if err := install(dir, mods...); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if n := batches.Load(); n != 1 {
+		t.Errorf("got %d batch requests, want 1", n)
+	}
+	if n := urlReqs.Load(); n != 0 {
+		t.Errorf("got %d download url requests, want 0", n)
+	}
+	for _, m := range mods {
+		if data, err := os.ReadFile(filepath.Join(dir, m.Path)); err != nil || sha1Hex(data) != m.Hash {
+			t.Errorf("installed %s = %q, %v", m.Path, data, err)
+		}
+	}
+
+	// resolved files are cached
+	os.Remove(filepath.Join(dir, mods[0].Path))
+	if err := install(dir, mods...); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if n := batches.Load(); n != 1 {
+		t.Errorf("got %d batch requests after a repair, want 1", n)
+	}
+
+	// outdated urls of the cache are looked up again, and replaced
+	cdn.Store("/cdn/")
+	for range 2 {
+		os.Remove(filepath.Join(dir, mods[0].Path))
+		if err := install(dir, mods...); err != nil {
+			t.Fatalf("Install() after a cdn move error = %v", err)
+		}
+	}
+	if n := urlReqs.Load(); n != 1 {
+		t.Errorf("got %d download url requests after a cdn move, want 1", n)
+	}
+
+	// mismatches fail the install before anything is downloaded
+	tests := []struct {
+		name string
+		mod  *Mod
+	}{
+		{"hash", func() *Mod { m := newMod(2, 20, "20.jar"); m.Hash = sha1Hex([]byte("other")); return m }()},
+		{"file name", newMod(2, 21, "other.jar")},
+		{"project", newMod(3, 22, "22.jar")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloads.Store(0)
+			ok := newMod(2, 23, "23.jar")
+			if err := install(t.TempDir(), ok, tt.mod); err == nil {
+				t.Error("Install() accepted a mismatching curseforge file")
+			}
+			if n := downloads.Load(); n != 0 {
+				t.Errorf("got %d downloads, want 0", n)
+			}
+		})
+	}
+}
+
+func sha1Hex(data []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(data))
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+)
+
+// HttpConfig configures the http client shared by every request of a
+// repository and the installer of its pack.
+type HttpConfig struct {
+	ConnectTimeout time.Duration // dialing and tls handshake, no limit if 0
+	ReadTimeout    time.Duration // longest wait for data from a connection, no limit if 0
+	Proxy          string        // proxy url, HTTP_PROXY and HTTPS_PROXY are used if empty
+	CaFiles        []string      // PEM bundles trusted in addition to the system roots
+	CertFile       string        // PEM client certificate, requires KeyFile
+	KeyFile        string
+	UserAgent      string // appended to the user agent
+	// Credentials are sent to their host only, never to other hosts like
+	// the curseforge api or mod downloads
+	Credentials []Credentials
+	NetrcFile   string // netrc file with more credentials, none if empty
+	// RateLimit limits the rate of all downloads together, also across
+	// clients sharing it, no limit if nil
+	RateLimit *RateLimiter
+}
+
+// NewClient returns an http client configured by c.
+func (c *HttpConfig) NewClient() (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: c.ConnectTimeout, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	transport.TLSHandshakeTimeout = c.ConnectTimeout
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil || c.ReadTimeout <= 0 {
+			return conn, err
+		}
+		return &readTimeoutConn{Conn: conn, timeout: c.ReadTimeout}, nil
+	}
+
+	var rt http.RoundTripper = transport
+	if c.RateLimit != nil {
+		rt = &rateLimitTransport{base: rt, limiter: c.RateLimit}
+	}
+	creds, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+	if len(creds) > 0 {
+		rt = &authTransport{base: rt, credentials: creds}
+	}
+	if c.UserAgent != "" {
+		rt = &userAgentTransport{base: rt, userAgent: c.userAgent()}
+	}
+	config := *c
+	return &http.Client{Transport: &configTransport{RoundTripper: rt, config: &config}}, nil
+}
+
+// credentials returns the credentials of c, followed by those of its netrc
+// file.
+func (c *HttpConfig) credentials() ([]Credentials, error) {
+	creds := c.Credentials
+	if c.NetrcFile != "" {
+		netrc, err := readNetrc(c.NetrcFile)
+		if err != nil {
+			return nil, fmt.Errorf("read netrc: %w", err)
+		}
+		creds = append(slices.Clip(creds), netrc...)
+	}
+	return creds, nil
+}
+
+func (c *HttpConfig) userAgent() string {
+	if c.UserAgent == "" {
+		return userAgent
+	}
+	return userAgent + " " + c.UserAgent
+}
+
+// configTransport keeps the config a client was created with, for requests
+// sent without the client, like those of git.
+type configTransport struct {
+	http.RoundTripper
+	config *HttpConfig
+}
+
+// clientConfig returns the config hc was created with by NewClient, or nil.
+func clientConfig(hc *http.Client) *HttpConfig {
+	if t, ok := hc.Transport.(*configTransport); ok {
+		return t.config
+	}
+	return nil
+}
+
+func (c *HttpConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{}
+	if len(c.CaFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, f := range c.CaFiles {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("read ca file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in ca file: %s", f)
+			}
+		}
+		config.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate requires both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// readTimeoutConn fails reads that receive no data within timeout, unlike a
+// client timeout it does not limit how long a large download may take.
+type readTimeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *readTimeoutConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(b)
+}
+
+// userAgentTransport replaces the user agent set by the request builders.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
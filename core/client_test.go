@@ -0,0 +1,135 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func TestHttpConfig_NewClient(t *testing.T) {
+	var gotUserAgent string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.UserAgent()
+		if r.URL.Path == "/slow" {
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("ok"))
+	}))
+	// the untrusted request fails the handshake
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestFile(t, caFile, string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: srv.Certificate().Raw,
+	})))
+
+	c, err := (&HttpConfig{}).NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := httpGetBytes(context.Background(), c, nil, srv.URL); err == nil {
+		t.Error("request to an untrusted server succeeded")
+	}
+
+	c, err = (&HttpConfig{
+		CaFiles:     []string{caFile},
+		ReadTimeout: 50 * time.Millisecond,
+		UserAgent:   "test/1.0",
+	}).NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := httpGetBytes(context.Background(), c, nil, srv.URL)
+	if err != nil || string(data) != "ok" {
+		t.Fatalf("httpGetBytes() = %q, %v", data, err)
+	}
+	if !strings.HasPrefix(gotUserAgent, userAgent) || !strings.HasSuffix(gotUserAgent, " test/1.0") {
+		t.Errorf("User-Agent = %q", gotUserAgent)
+	}
+	if _, err := httpGetBytes(context.Background(), c, nil, srv.URL+"/slow"); err == nil {
+		t.Error("read timeout did not fail a stalled response")
+	}
+}
+
+func TestHttpConfig_RateLimit(t *testing.T) {
+	body := strings.Repeat("x", 48<<10)
+	small := strings.Repeat("x", 10<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery == "small" {
+			w.Write([]byte(small))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	limiter := NewRateLimiter(64 << 10)
+	c, err := (&HttpConfig{RateLimit: limiter}).NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func() time.Duration {
+		t.Helper()
+		start := time.Now()
+		var eg errgroup.Group
+		for range 2 {
+			eg.Go(func() error {
+				_, err := httpGetBytes(context.Background(), c, nil, srv.URL)
+				return err
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+
+	// the limit is shared, 96KiB at 64KiB/s take another 0.5s after the
+	// initial burst of 64KiB. a slow machine only takes longer, so there is
+	// no upper bound.
+	if d := get(); d < 400*time.Millisecond {
+		t.Errorf("limited downloads took %v, want at least 0.5s", d)
+	}
+
+	// without a limit nothing is waited for, which is not measured, as
+	// a loaded machine can take as long as the limited downloads
+	limiter.SetLimit(0)
+	if l := limiter.Limit(); l != 0 {
+		t.Errorf("Limit() = %d after removing the limit", l)
+	}
+	get()
+
+	// every byte received is taken from the limiter, at 1B/s the 32KiB
+	// burst barely refills while the test runs
+	limiter = NewRateLimiter(1)
+	c, err = (&HttpConfig{RateLimit: limiter}).NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := httpGetBytes(context.Background(), c, nil, srv.URL+"?small"); err != nil {
+		t.Fatal(err)
+	}
+	want := float64(minRateBurst - len(small))
+	if tokens := limiter.l.Tokens(); tokens < want || tokens > want+60 {
+		t.Errorf("%v tokens left, want %v", tokens, want)
+	}
+}
@@ -9,6 +9,7 @@ package core
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/carlmjohnson/requests"
@@ -25,9 +26,68 @@ type cfDownloadUrlRes struct {
 	Data string `json:"data"`
 }
 
+type cfFilesRes struct {
+	Data []CurseFile `json:"data"`
+}
+
+type cfModsRes struct {
+	Data []CurseMod `json:"data"`
+}
+
+// hash algorithms used by the curseforge api
+const (
+	cfAlgoSha1 = 1
+	cfAlgoMd5  = 2
+)
+
+// curseforge class ids of non-mod project types modpacks usually contain
+const (
+	cfClassResourcePacks = 12
+	cfClassShaderPacks   = 6552
+)
+
+type CurseFileHash struct {
+	Value string `json:"value"`
+	Algo  int    `json:"algo"`
+}
+
+type CurseFile struct {
+	ID          int             `json:"id"`
+	ModID       int             `json:"modId"`
+	DisplayName string          `json:"displayName"`
+	FileName    string          `json:"fileName"`
+	Hashes      []CurseFileHash `json:"hashes"`
+	FileLength  int64           `json:"fileLength"`
+	DownloadUrl string          `json:"downloadUrl"`
+}
+
+// Hash returns the preferred hash of the file and its format,
+// or empty strings if curseforge does not provide any.
+func (f *CurseFile) Hash() (hashFormat string, hash string) {
+	for _, algo := range []int{cfAlgoSha1, cfAlgoMd5} {
+		for _, h := range f.Hashes {
+			if h.Algo == algo && h.Value != "" {
+				if algo == cfAlgoSha1 {
+					return "sha1", h.Value
+				}
+				return "md5", h.Value
+			}
+		}
+	}
+	return "", ""
+}
+
+type CurseMod struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Slug    string `json:"slug"`
+	ClassID int    `json:"classId"`
+}
+
 type CurseClient struct {
 	apiKey     string
 	httpClient *requests.Builder
+	retry      *RetryPolicy
 }
 
 func NewCurseClient(apiKey string) *CurseClient {
@@ -37,6 +97,25 @@ func NewCurseClient(apiKey string) *CurseClient {
 			Clone().
 			BaseURL(cf_api_host).
 			Header("X-API-Key", apiKey),
+		retry: DefaultRetryPolicy(),
+	}
+}
+
+// WithHttpClient returns a copy of c sending its requests with hc.
+func (c *CurseClient) WithHttpClient(hc *http.Client) *CurseClient {
+	return &CurseClient{
+		apiKey:     c.apiKey,
+		httpClient: c.httpClient.Clone().Client(hc),
+		retry:      c.retry,
+	}
+}
+
+// WithRetryPolicy returns a copy of c retrying its requests with p.
+func (c *CurseClient) WithRetryPolicy(p *RetryPolicy) *CurseClient {
+	return &CurseClient{
+		apiKey:     c.apiKey,
+		httpClient: c.httpClient,
+		retry:      p,
 	}
 }
 
@@ -53,7 +132,23 @@ func (c *CurseClient) getJson(ctx context.Context, path string, v any) error {
 		return fmt.Errorf("invalid curseforge api key")
 	}
 
-	err := c.httpClient.Path(path).ToJSON(&v).Fetch(context.WithoutCancel(ctx))
+	err := c.retry.do(ctx, func() error {
+		return c.httpClient.Clone().Path(path).ToJSON(&v).Fetch(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("curseforge api: %w", err)
+	}
+	return nil
+}
+
+func (c *CurseClient) postJson(ctx context.Context, path string, body any, v any) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("invalid curseforge api key")
+	}
+
+	err := c.retry.do(ctx, func() error {
+		return c.httpClient.Clone().Path(path).BodyJSON(body).ToJSON(&v).Fetch(context.WithoutCancel(ctx))
+	})
 	if err != nil {
 		return fmt.Errorf("curseforge api: %w", err)
 	}
@@ -69,3 +164,23 @@ func (c *CurseClient) GetDownloadUrl(ctx context.Context, d *CurseforgeData) (st
 	}
 	return resUrl.Data, nil
 }
+
+// GetFiles returns the files with the given ids in a single request.
+func (c *CurseClient) GetFiles(ctx context.Context, fileIds []int) ([]CurseFile, error) {
+	var res cfFilesRes
+	err := c.postJson(ctx, "/v1/mods/files", map[string][]int{"fileIds": fileIds}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// GetMods returns the projects with the given ids in a single request.
+func (c *CurseClient) GetMods(ctx context.Context, modIds []int) ([]CurseMod, error) {
+	var res cfModsRes
+	err := c.postJson(ctx, "/v1/mods", map[string][]int{"modIds": modIds}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/carlmjohnson/requests"
+	packwiz "github.com/packwiz/packwiz/core"
+)
+
+// partialMeta is stored next to a partial download, it holds the validator
+// used to make sure a resumed download continues the same content.
+type partialMeta struct {
+	Url          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// validator returns the value for an If-Range header, weak etags can not be
+// used for range requests.
+func (m *partialMeta) validator() string {
+	if m.ETag != "" && !strings.HasPrefix(m.ETag, "W/") {
+		return m.ETag
+	}
+	return m.LastModified
+}
+
+// partialPath returns where the download of m is kept until it is verified.
+// Partial downloads are keyed by the expected hash, so a changed file never
+// resumes the download of an older one.
+func (i *LocalInstaller) partialPath(m *Mod) (string, error) {
+	name := m.HashFormat + "-" + strings.ToLower(m.Hash)
+	if m.Hash == "" || strings.ContainsAny(name, `/\.`) {
+		return "", fmt.Errorf("invalid hash %q: %s", m.Hash, m.Path)
+	}
+	return filepath.Join(i.BaseDir, ".pw-install", "partial", name), nil
+}
+
+// downloadFile downloads url to the file at p. The download is kept under
+// .pw-install until its hash is verified, and an interrupted download is
+// resumed from there on the next run. Mirrors of url are tried in order
+// when one fails or serves a file not matching the hash.
+func (i *LocalInstaller) downloadFile(ctx context.Context, url string, m *Mod, p string) error {
+	return i.fetchFile(ctx, i.Retry, i.Rewrite.urls(url), m, p)
+}
+
+// fetchFile downloads the file of m to p from the first of urls serving
+// it, see downloadFile.
+func (i *LocalInstaller) fetchFile(ctx context.Context, retry *RetryPolicy, urls []string, m *Mod, p string) error {
+	partial, err := i.partialPath(m)
+	if err != nil {
+		return err
+	}
+	// files with the same content share their partial download
+	mu, _ := i.partialLocks.LoadOrStore(partial, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	// a retry resumes where the failed attempt stopped
+	err = retry.do(ctx, func() error {
+		return tryMirrors(ctx, urls, func(u string) error {
+			err := resumeDownload(ctx, i.httpClient, u, partial, m.HashFormat, m.Hash)
+			if errors.Is(err, errHashMismatch) {
+				return fmt.Errorf("download %w: %s", err, RedactUrl(u))
+			}
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	if err := renameFile(partial, p); err == nil {
+		return nil
+	}
+	// .pw-install may be on another file system than p
+	err = writeValidFile(p, m.HashFormat, m.Hash, func(w io.Writer) error {
+		f, err := os.Open(partial)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return os.Remove(partial)
+}
+
+// resumeDownload downloads url to the file at partial, continuing from its
+// current size with a range request when the server supports it. If the
+// server does not, or the content changed, the whole file is fetched again.
+// The partial file is removed if the finished download does not match hash.
+func resumeDownload(ctx context.Context, c *http.Client, url string, partial string, hashFormat, hash string) error {
+	hasher, err := packwiz.GetHashImpl(hashFormat)
+	if err != nil {
+		return fmt.Errorf("unsupported hash format %q: %w", hashFormat, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(partial), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	metaPath := partial + ".json"
+
+	// hash what was downloaded before, leaving f at its end
+	offset, err := io.Copy(hasher, f)
+	if err != nil {
+		return err
+	}
+	if offset > 0 && strings.EqualFold(hash, fmt.Sprintf("%x", hasher.Sum(nil))) {
+		os.Remove(metaPath)
+		return nil
+	}
+
+	rb := defaultRequestBuilder.
+		Clone().
+		Client(c).
+		BaseURL(url).
+		CheckStatus(http.StatusOK, http.StatusPartialContent)
+	ranged := false
+	if meta := readPartialMeta(metaPath); offset > 0 && meta != nil && meta.Url == url && meta.validator() != "" {
+		rb.Header("Range", fmt.Sprintf("bytes=%d-", offset)).
+			Header("If-Range", meta.validator())
+		ranged = true
+	}
+	err = rb.Handle(func(res *http.Response) error {
+		if res.StatusCode != http.StatusPartialContent || contentRangeStart(res) != offset {
+			// the server sent the whole file
+			hasher.Reset()
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			meta := &partialMeta{
+				Url:          url,
+				ETag:         res.Header.Get("ETag"),
+				LastModified: res.Header.Get("Last-Modified"),
+			}
+			if err := writePartialMeta(metaPath, meta); err != nil {
+				return err
+			}
+		}
+		_, err := io.Copy(io.MultiWriter(f, hasher), res.Body)
+		return err
+	}).Fetch(ctx)
+	if ranged && requests.HasStatusErr(err, http.StatusRequestedRangeNotSatisfiable) {
+		// the partial file is no prefix of the content, start over, the
+		// empty partial file is requested without a range
+		f.Close()
+		removePartial(partial)
+		return resumeDownload(ctx, c, url, partial, hashFormat, hash)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(hash, fmt.Sprintf("%x", hasher.Sum(nil))) {
+		f.Close()
+		removePartial(partial)
+		return errHashMismatch
+	}
+	os.Remove(metaPath)
+	return f.Close()
+}
+
+// contentRangeStart returns the first byte of a partial response, or -1.
+func contentRangeStart(res *http.Response) int64 {
+	// Content-Range: bytes <start>-<end>/<size>
+	r, ok := strings.CutPrefix(res.Header.Get("Content-Range"), "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(r, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func readPartialMeta(p string) *partialMeta {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	var meta = new(partialMeta)
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil
+	}
+	return meta
+}
+
+func writePartialMeta(p string, meta *partialMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+func removePartial(p string) {
+	os.Remove(p)
+	os.Remove(p + ".json")
+}
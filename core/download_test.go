@@ -0,0 +1,185 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocalInstaller_ResumeDownload(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	tests := []struct {
+		name         string
+		ranges       bool
+		wantServed   int
+		wantRangeReq bool
+	}{
+		{"range", true, len(content) / 2, true},
+		{"no range support", false, len(content), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var served int
+			var gotRange string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRange = r.Header.Get("Range")
+				if !tt.ranges {
+					r.Header.Del("Range")
+				}
+				w.Header().Set("ETag", `"v1"`)
+				cw := &countingResponseWriter{ResponseWriter: w}
+				http.ServeContent(cw, r, "a.jar", time.Time{}, bytes.NewReader(content))
+				served = cw.n
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			inst, err := NewLocalInstaller(&Pack{}, dir, Side_Both)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := &Mod{
+				Path:       "mods/a.jar",
+				Hash:       sha256Hex(content),
+				HashFormat: "sha256",
+				Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/a.jar"},
+			}
+
+			// an interrupted download of the first half
+			partial, err := inst.partialPath(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, partial, string(content[:len(content)/2]))
+			err = writePartialMeta(partial+".json", &partialMeta{Url: m.Downloads.Data, ETag: `"v1"`})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := inst.InstallMod(context.Background(), m); err != nil {
+				t.Fatalf("InstallMod() error = %v", err)
+			}
+			if (gotRange != "") != tt.wantRangeReq {
+				t.Errorf("Range = %q", gotRange)
+			}
+			if served != tt.wantServed {
+				t.Errorf("served %d bytes, want %d", served, tt.wantServed)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "mods", "a.jar"))
+			if err != nil || !bytes.Equal(data, content) {
+				t.Errorf("installed content mismatched, %v", err)
+			}
+			if exists(t, partial) || exists(t, partial+".json") {
+				t.Error("partial download was not cleaned up")
+			}
+		})
+	}
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	n int
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += n
+	return n, err
+}
+
+func TestLocalInstaller_ResumeDownloadUnsatisfiable(t *testing.T) {
+	var reqs atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	}))
+	defer srv.Close()
+
+	inst, err := NewLocalInstaller(&Pack{}, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Mod{
+		Path:       "mods/a.jar",
+		Hash:       sha256Hex([]byte("jar")),
+		HashFormat: "sha256",
+		Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/a.jar"},
+	}
+	partial, err := inst.partialPath(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, partial, "ja")
+	if err := writePartialMeta(partial+".json", &partialMeta{Url: m.Downloads.Data, ETag: `"v1"`}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the download starts over once, a 416 without a range is an error
+	if err := inst.InstallMod(context.Background(), m); err == nil {
+		t.Fatal("InstallMod() error = nil")
+	}
+	if n := reqs.Load(); n != 2 {
+		t.Errorf("sent %d requests, want 2", n)
+	}
+}
+
+func TestLocalInstaller_ResumeSourceFile(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	var reqs atomic.Int32
+	var gotRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if reqs.Add(1) == 1 {
+			// the connection drops in the middle of the file
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		gotRange = r.Header.Get("Range")
+		http.ServeContent(w, r, "a.toml", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	base, err := url.Parse(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewLocalInstaller(&Pack{source: &httpSource{base: base, httpClient: http.DefaultClient}}, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.Retry = &RetryPolicy{Retries: 1, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	m := &Mod{
+		Path:       "config/a.toml",
+		Hash:       sha256Hex(content),
+		HashFormat: "sha256",
+		Downloads:  &Download{Type: DL_Source, Data: "config/a.toml"},
+	}
+	if err := inst.InstallMod(context.Background(), m); err != nil {
+		t.Fatalf("InstallMod() error = %v", err)
+	}
+	if want := fmt.Sprintf("bytes=%d-", len(content)/2); gotRange != want {
+		t.Errorf("Range = %q, want %q", gotRange, want)
+	}
+	data, err := os.ReadFile(filepath.Join(inst.BaseDir, "config", "a.toml"))
+	if err != nil || !bytes.Equal(data, content) {
+		t.Errorf("installed content mismatched, %v", err)
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gitSource reads pack files from a single commit of a git repository.
+// The commit is fetched into a temporary bare repository with the git
+// command line, which has to be installed.
+type gitSource struct {
+	dir      string
+	root     string
+	revision string
+	env      []string // http settings passed to git
+
+	// batch reads every file through a single git cat-file process,
+	// started on the first read
+	batchMu  sync.Mutex
+	batch    *exec.Cmd
+	batchIn  io.WriteCloser
+	batchOut *bufio.Reader
+}
+
+// parseGitUrl splits a pack URL of the form
+// git+<remote>#<ref>[:<path to pack.toml>] into its parts.
+// An empty ref selects the remote HEAD.
+func parseGitUrl(u *url.URL) (remote, ref, packPath string) {
+	r := *u
+	r.Scheme = strings.TrimPrefix(r.Scheme, "git+")
+	r.Fragment = ""
+	r.RawFragment = ""
+
+	// ':' is not allowed in ref names, so it can separate the path
+	ref, packPath, _ = strings.Cut(u.Fragment, ":")
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if packPath == "" {
+		packPath = "pack.toml"
+	}
+	return r.String(), ref, path.Clean(strings.TrimPrefix(packPath, "/"))
+}
+
+// openGitSource fetches the commit selected by a git+ pack URL and returns
+// the source along with the path of pack.toml relative to the source root.
+// The remote is rewritten like the other urls of the pack, its mirrors are
+// fetched from in order until one has the ref. The settings of hc are passed
+// to git if it was created by HttpConfig.NewClient.
+func openGitSource(ctx context.Context, u *url.URL, hc *http.Client, rewrite RewriteRules) (*gitSource, string, error) {
+	remote, ref, packPath := parseGitUrl(u)
+
+	dir, err := os.MkdirTemp("", "packwiz-installer-git-")
+	if err != nil {
+		return nil, "", err
+	}
+	s := &gitSource{
+		dir:  dir,
+		root: path.Dir(packPath),
+	}
+
+	if _, err := s.git(ctx, "init", "--bare", "--quiet"); err != nil {
+		s.Close()
+		return nil, "", err
+	}
+
+	err = tryMirrors(ctx, rewrite.urls(remote), func(remote string) error {
+		return s.fetch(ctx, hc, remote, ref)
+	})
+	if err != nil {
+		s.Close()
+		return nil, "", err
+	}
+	return s, path.Base(packPath), nil
+}
+
+// fetch fetches ref from remote and resolves it to the revision of s.
+func (s *gitSource) fetch(ctx context.Context, hc *http.Client, remote, ref string) error {
+	s.env = nil
+	if c := clientConfig(hc); c != nil {
+		env, err := gitHttpEnv(c, remote, s.dir)
+		if err != nil {
+			return err
+		}
+		s.env = env
+	}
+
+	// a shallow fetch is enough for branches, tags and full commit ids,
+	// anything else needs the whole history to be resolved.
+	rev := "FETCH_HEAD"
+	if _, err := s.git(ctx, "fetch", "--quiet", "--depth=1", "--", remote, ref); err != nil {
+		_, err = s.git(ctx, "fetch", "--quiet", "--tags", "--", remote, "+refs/heads/*:refs/remotes/origin/*")
+		if err != nil {
+			return err
+		}
+		rev = ref
+	}
+
+	out, err := s.git(ctx, "rev-parse", "--verify", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return fmt.Errorf("resolve git ref %q: %w", ref, err)
+	}
+	s.revision = strings.TrimSpace(string(out))
+	return nil
+}
+
+func (s *gitSource) command(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Env = append(cmd.Env, s.env...)
+	return cmd
+}
+
+func (s *gitSource) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := s.command(ctx, args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+	return out, nil
+}
+
+// systemCaFiles are the usual locations of the system certificate bundle.
+var systemCaFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// gitHttpEnv returns the environment passing c to git for fetching remote.
+// Settings are passed as GIT_CONFIG_* variables, so that credentials are not
+// on the command line. Extra certificate authorities are written to a bundle
+// in dir, along with the system bundle, as git trusts only one of them.
+// Connect timeouts and rate limits are not supported by git.
+func gitHttpEnv(c *HttpConfig, remote string, dir string) ([]string, error) {
+	env := []string{"GIT_HTTP_USER_AGENT=" + c.userAgent()}
+	var config []string
+	set := func(key, value string) {
+		config = append(config, key, value)
+	}
+
+	if c.Proxy != "" {
+		set("http.proxy", c.Proxy)
+	}
+	if len(c.CaFiles) > 0 {
+		bundle := &bytes.Buffer{}
+		files := c.CaFiles
+		if f := os.Getenv("SSL_CERT_FILE"); f != "" {
+			files = append([]string{f}, files...)
+		} else if i := slices.IndexFunc(systemCaFiles, func(f string) bool {
+			_, err := os.Stat(f)
+			return err == nil
+		}); i >= 0 {
+			files = append([]string{systemCaFiles[i]}, files...)
+		}
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("read ca file: %w", err)
+			}
+			bundle.Write(data)
+			bundle.WriteByte('\n')
+		}
+		p := filepath.Join(dir, "ca-bundle.pem")
+		if err := os.WriteFile(p, bundle.Bytes(), 0o600); err != nil {
+			return nil, err
+		}
+		set("http.sslCAInfo", p)
+	}
+	if c.CertFile != "" {
+		set("http.sslCert", c.CertFile)
+		set("http.sslKey", c.KeyFile)
+	}
+	if c.ReadTimeout > 0 {
+		// aborts transfers slower than a byte per second for that long
+		set("http.lowSpeedLimit", "1")
+		set("http.lowSpeedTime", strconv.Itoa(int(math.Ceil(c.ReadTimeout.Seconds()))))
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return nil, err
+	}
+	// credentials in the url take precedence, like for git itself
+	if (u.Scheme == "http" || u.Scheme == "https") && u.User == nil {
+		creds, err := c.credentials()
+		if err != nil {
+			return nil, err
+		}
+		for _, cred := range creds {
+			if cred.matches(u) {
+				set("http."+u.Scheme+"://"+u.Host+"/.extraHeader", "Authorization: "+cred.authorization())
+				break
+			}
+		}
+	}
+
+	env = append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(len(config)/2))
+	for i := 0; i < len(config); i += 2 {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i/2, config[i]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i/2, config[i+1]),
+		)
+	}
+	return env, nil
+}
+
+// ReadFile reads a file of the commit. Reads are passed one at a time to a
+// long running git cat-file --batch, rather than starting git for each of
+// the many metafiles of a pack.
+func (s *gitSource) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	object := s.revision + ":" + path.Join(s.root, name)
+	// the batch protocol is line based
+	if strings.ContainsAny(object, "\r\n") {
+		return nil, fmt.Errorf("invalid file name %q", name)
+	}
+
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	if s.batch == nil {
+		if err := s.startBatch(); err != nil {
+			return nil, err
+		}
+	}
+	data, err := s.readBatch(object)
+	if err != nil {
+		// the output may be out of step with the requests now
+		s.stopBatch()
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("git cat-file: %s: %w", name, fs.ErrNotExist)
+	}
+	return data, nil
+}
+
+func (s *gitSource) startBatch() error {
+	// not bound to the context of a single read
+	cmd := s.command(context.Background(), "cat-file", "--batch")
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	s.batch = cmd
+	s.batchIn = in
+	s.batchOut = bufio.NewReader(out)
+	return nil
+}
+
+func (s *gitSource) stopBatch() {
+	if s.batch == nil {
+		return
+	}
+	s.batchIn.Close()
+	s.batch.Wait()
+	s.batch = nil
+}
+
+// readBatch requests a blob from the batch process, it returns nil if the
+// object does not exist.
+func (s *gitSource) readBatch(object string) ([]byte, error) {
+	if _, err := io.WriteString(s.batchIn, object+"\n"); err != nil {
+		return nil, err
+	}
+	header, err := s.batchOut.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	// "<oid> <type> <size>" or "<object> missing"
+	fields := strings.Fields(header)
+	if len(fields) > 0 && fields[len(fields)-1] == "missing" {
+		return nil, nil
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected output %q", header)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected output %q", header)
+	}
+	// the content is followed by a newline
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(s.batchOut, data); err != nil {
+		return nil, err
+	}
+	if fields[1] != "blob" {
+		return nil, fmt.Errorf("%s is a %s, not a file", object, fields[1])
+	}
+	return data[:size], nil
+}
+
+// Revision returns the resolved commit id.
+func (s *gitSource) Revision() string {
+	return s.revision
+}
+
+func (s *gitSource) Close() error {
+	s.batchMu.Lock()
+	s.stopBatch()
+	s.batchMu.Unlock()
+	return os.RemoveAll(s.dir)
+}
@@ -7,7 +7,13 @@
 package core
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 
 	packwiz "github.com/packwiz/packwiz/core"
@@ -22,6 +28,9 @@ var PreferredHashList = []string{
 	"sha512",
 }
 
+// errHashMismatch is returned when written data does not match its hash.
+var errHashMismatch = errors.New("hash mismatched")
+
 // MatchHash verifies that the given data matches the provided hash string
 // using the specified hashFormat. It supports all hash algorithms known
 // to the underlying packwiz library.
@@ -44,3 +53,92 @@ func MatchHash(data []byte, hashFormat, expected string) (bool, error) {
 
 	return match, nil
 }
+
+// hashReader returns the hex encoded hash of everything read from r in the
+// given hashFormat.
+func hashReader(r io.Reader, hashFormat string) (string, error) {
+	hasher, err := packwiz.GetHashImpl(hashFormat)
+	if err != nil {
+		return "", fmt.Errorf("unsupported hash format %q: %w", hashFormat, err)
+	}
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+func sha256Hex(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// hashFile returns the hex encoded hashes of the file at p in each of the
+// given formats, along with its size. The file is read only once.
+func hashFile(p string, hashFormats ...string) (map[string]string, int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	hashers := make([]hash.Hash, len(hashFormats))
+	writers := make([]io.Writer, len(hashFormats))
+	for i, hashFormat := range hashFormats {
+		hasher, err := packwiz.GetHashImpl(hashFormat)
+		if err != nil {
+			return nil, 0, fmt.Errorf("unsupported hash format %q: %w", hashFormat, err)
+		}
+		hashers[i] = hasher
+		writers[i] = hasher
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sums := make(map[string]string, len(hashFormats))
+	for i, hashFormat := range hashFormats {
+		sums[hashFormat] = fmt.Sprintf("%x", hashers[i].Sum(nil))
+	}
+	return sums, n, nil
+}
+
+// writeValidFile writes the data copied by fetch to p, hashing it along the
+// way. The data is streamed into a temporary file next to p, which is only
+// renamed into place once the hash matches, so p is never left half written.
+func writeValidFile(p string, hashFormat, expected string, fetch func(w io.Writer) error) error {
+	if expected == "" {
+		return fmt.Errorf("expected hash is empty (format=%s)", hashFormat)
+	}
+	hasher, err := packwiz.GetHashImpl(hashFormat)
+	if err != nil {
+		return fmt.Errorf("unsupported hash format %q: %w", hashFormat, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// no-op once renamed
+		f.Close()
+		os.Remove(f.Name())
+	}()
+
+	if err := fetch(io.MultiWriter(f, hasher)); err != nil {
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if !strings.EqualFold(expected, fmt.Sprintf("%x", hasher.Sum(nil))) {
+		return errHashMismatch
+	}
+	return renameFile(f.Name(), p)
+}
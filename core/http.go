@@ -9,8 +9,14 @@ package core
 import (
 	"bytes"
 	"context"
-	"fmt"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/carlmjohnson/requests"
 )
@@ -26,36 +32,196 @@ func newRequestBuilder(c *http.Client) *requests.Builder {
 		UserAgent(userAgent)
 }
 
-func httpGetJson(ctx context.Context, c *http.Client, url string, v any) error {
-	return defaultRequestBuilder.Clone().Client(c).BaseURL(url).ToJSON(&v).Fetch(ctx)
+// RetryPolicy controls how failed requests are retried. Delays grow
+// exponentially from MinDelay up to MaxDelay, with random jitter so that
+// parallel downloads do not retry in lockstep.
+type RetryPolicy struct {
+	Retries  int           // retries after the first attempt, 0 disables retrying
+	MinDelay time.Duration // delay before the first retry
+	MaxDelay time.Duration // longest delay, including ones asked for by Retry-After
 }
 
-func httpGetBytes(ctx context.Context, c *http.Client, url string) ([]byte, error) {
-	buf := &bytes.Buffer{}
-	err := defaultRequestBuilder.
-		Clone().
-		Client(c).
-		BaseURL(url).
-		ToBytesBuffer(buf).
-		Fetch(context.WithoutCancel(ctx))
-	if err != nil {
-		return nil, err
+// DefaultRetryPolicy returns the policy used by repositories, installers and
+// the curseforge client unless they are given another one.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		Retries:  4,
+		MinDelay: time.Second,
+		MaxDelay: 30 * time.Second,
 	}
-	return buf.Bytes(), nil
 }
 
-func httpGetValidBytes(ctx context.Context, c *http.Client, url string, hashFormat string, hash string) ([]byte, error) {
-	data, err := httpGetBytes(ctx, c, url)
+// do calls fn until it succeeds, fails with an error that is not worth
+// retrying, or the retries of the policy are used up. A nil policy calls fn
+// only once.
+func (p *RetryPolicy) do(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || p == nil || attempt >= p.Retries || !isRetryable(err) {
+			return err
+		}
+		t := time.NewTimer(p.delay(attempt, err))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// delay returns how long to wait before the retry following attempt. A
+// Retry-After header takes precedence, a server asking for more than MaxDelay
+// is retried after MaxDelay.
+func (p *RetryPolicy) delay(attempt int, err error) time.Duration {
+	if d, ok := retryAfter(err); ok {
+		return min(d, p.MaxDelay)
+	}
+
+	d := p.MinDelay
+	for range attempt {
+		if d >= p.MaxDelay/2 {
+			d = p.MaxDelay
+			break
+		}
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	// full jitter over the upper half
+	if d > 1 {
+		d = d/2 + rand.N(d/2)
+	}
+	return d
+}
+
+// noRetryError marks an error that would otherwise be retried as final.
+type noRetryError struct{ error }
+
+func (e noRetryError) Unwrap() error { return e.error }
+
+// isRetryable returns whether err is transient, like a dropped connection or
+// an overloaded server, rather than a problem retrying won't fix.
+func isRetryable(err error) bool {
+	if errors.As(err, new(noRetryError)) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if se := new(requests.ResponseError); errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusRequestTimeout,
+			http.StatusTooEarly,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// connections closed by the server before or while sending the body
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter returns the delay asked for by the Retry-After header of a
+// failed response.
+func retryAfter(err error) (time.Duration, bool) {
+	se := new(requests.ResponseError)
+	if !errors.As(err, &se) {
+		return 0, false
+	}
+	v := se.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// RedactUrl hides the credentials of a url for printing: its user info is
+// removed, and the values of its query, which may hold tokens, are masked.
+func RedactUrl(s string) string {
+	u, err := url.Parse(s)
 	if err != nil {
-		return nil, err
+		return s
 	}
+	u.User = nil
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			q[k] = []string{"xxxxx"}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
 
-	valid, err := MatchHash(data, hashFormat, hash)
+func httpGetJson(ctx context.Context, c *http.Client, retry *RetryPolicy, url string, v any) error {
+	return retry.do(ctx, func() error {
+		return defaultRequestBuilder.Clone().Client(c).BaseURL(url).ToJSON(&v).Fetch(ctx)
+	})
+}
+
+func httpGetBytes(ctx context.Context, c *http.Client, retry *RetryPolicy, url string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := retry.do(ctx, func() error {
+		buf.Reset()
+		return defaultRequestBuilder.
+			Clone().
+			Client(c).
+			BaseURL(url).
+			ToBytesBuffer(buf).
+			Fetch(context.WithoutCancel(ctx))
+	})
 	if err != nil {
 		return nil, err
 	}
-	if !valid {
-		return nil, fmt.Errorf("download hash mismatched: %s", url)
-	}
-	return data, nil
+	return buf.Bytes(), nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// httpCopy copies the body of url to w. As written data can not be taken
+// back, a request is only retried if it failed before writing anything.
+func httpCopy(ctx context.Context, c *http.Client, retry *RetryPolicy, url string, w io.Writer) error {
+	cw := &countingWriter{w: w}
+	return retry.do(ctx, func() error {
+		err := defaultRequestBuilder.
+			Clone().
+			Client(c).
+			BaseURL(url).
+			ToWriter(cw).
+			Fetch(ctx)
+		if err != nil && cw.n > 0 {
+			return noRetryError{err}
+		}
+		return err
+	})
 }
@@ -0,0 +1,164 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// httpCache keeps responses on disk along with their validators, so that
+// unchanged files are answered with a 304 instead of being downloaded again.
+// Cached data is returned like a fresh response, callers verify it the same.
+type httpCache struct {
+	dir string
+
+	mu   sync.Mutex
+	used map[string]bool // keys read since the cache was created
+}
+
+type httpCacheMeta struct {
+	Url          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Sha256       string `json:"sha256"` // of the cached data, to detect corrupted entries
+}
+
+func newHttpCache(dir string) *httpCache {
+	return &httpCache{dir: dir, used: make(map[string]bool)}
+}
+
+func (c *httpCache) key(url string) string {
+	return sha256Hex([]byte(url))
+}
+
+// get returns the body of url, from the cache if the server reports it has
+// not been modified.
+func (c *httpCache) get(ctx context.Context, hc *http.Client, retry *RetryPolicy, url string) ([]byte, error) {
+	key := c.key(url)
+	dataPath := filepath.Join(c.dir, key)
+	c.mu.Lock()
+	c.used[key] = true
+	c.mu.Unlock()
+
+	cached, cachedMeta := c.read(key)
+	buf := &bytes.Buffer{}
+	var (
+		meta        *httpCacheMeta
+		notModified bool
+	)
+	err := retry.do(ctx, func() error {
+		buf.Reset()
+		rb := defaultRequestBuilder.
+			Clone().
+			Client(hc).
+			BaseURL(url).
+			CheckStatus(http.StatusOK, http.StatusNotModified)
+		if cachedMeta != nil {
+			rb.HeaderOptional("If-None-Match", cachedMeta.ETag).
+				HeaderOptional("If-Modified-Since", cachedMeta.LastModified)
+		}
+		return rb.Handle(func(res *http.Response) error {
+			notModified = res.StatusCode == http.StatusNotModified
+			if notModified {
+				return nil
+			}
+			meta = &httpCacheMeta{
+				Url:          url,
+				ETag:         res.Header.Get("ETag"),
+				LastModified: res.Header.Get("Last-Modified"),
+			}
+			_, err := io.Copy(buf, res.Body)
+			return err
+		}).Fetch(context.WithoutCancel(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if notModified {
+		if cachedMeta == nil {
+			return nil, fmt.Errorf("not modified response without a cached file: %s", RedactUrl(url))
+		}
+		return cached, nil
+	}
+
+	data := buf.Bytes()
+	c.evict(url)
+	if meta.ETag == "" && meta.LastModified == "" {
+		return data, nil
+	}
+	// the cache is only an optimization, failing to write it is no error
+	meta.Sha256 = sha256Hex(data)
+	if err := os.MkdirAll(c.dir, os.ModePerm); err == nil {
+		if err := os.WriteFile(dataPath, data, 0o644); err == nil {
+			c.writeMeta(key, meta)
+		}
+	}
+	return data, nil
+}
+
+// read returns the cached data of key and its validators, or nil if it is
+// not cached.
+func (c *httpCache) read(key string) ([]byte, *httpCacheMeta) {
+	metaData, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil {
+		return nil, nil
+	}
+	var meta = new(httpCacheMeta)
+	if err := json.Unmarshal(metaData, meta); err != nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err != nil || sha256Hex(data) != meta.Sha256 {
+		return nil, nil
+	}
+	return data, meta
+}
+
+func (c *httpCache) writeMeta(key string, meta *httpCacheMeta) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return
+	}
+	os.WriteFile(filepath.Join(c.dir, key+".json"), data, 0o644)
+}
+
+// evict removes url from the cache.
+func (c *httpCache) evict(url string) {
+	key := c.key(url)
+	os.Remove(filepath.Join(c.dir, key+".json"))
+	os.Remove(filepath.Join(c.dir, key))
+}
+
+// prune removes every entry that was not read since the cache was created.
+func (c *httpCache) prune() error {
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		key := strings.TrimSuffix(e.Name(), ".json")
+		if !c.used[key] {
+			os.Remove(filepath.Join(c.dir, e.Name()))
+		}
+	}
+	return nil
+}
@@ -10,11 +10,15 @@ import (
 	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
 
 	"golang.org/x/sync/errgroup"
@@ -25,6 +29,8 @@ type Updates struct {
 	Added     []*Mod
 	Removed   []*Mod
 	Unchanged []*Mod
+	// Preserved files exist already and were left alone
+	Preserved []*Mod
 }
 
 func (u *Updates) String() string {
@@ -41,15 +47,50 @@ func (u *Updates) String() string {
 	for _, m := range u.Unchanged {
 		s += fmt.Sprintf("  %s\n", m.Path)
 	}
+	if len(u.Preserved) > 0 {
+		s += "Preserved:\n"
+		for _, m := range u.Preserved {
+			s += fmt.Sprintf("  %s\n", m.Path)
+		}
+	}
 	return s
 }
 
+// InstallState records details about the last installation in a directory
+type InstallState struct {
+	Name    string `json:"name,omitempty"`
+	Author  string `json:"author,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Revision is the source revision the pack was installed from, if any
+	Revision string            `json:"revision,omitempty"`
+	Versions map[string]string `json:"versions,omitempty"`
+	// PackHash and IndexHash identify the pack.toml and index that were
+	// installed, they are empty for packs without them like .mrpack files
+	PackHash  string `json:"packHash,omitempty"`  // "<format>:<hash>" of pack.toml
+	IndexHash string `json:"indexHash,omitempty"` // "<format>:<hash>" of index.toml
+	Side      Side   `json:"side,omitempty"`
+}
+
 // LocalInstaller manages installation and updates of mods in a local directory
 type LocalInstaller struct {
 	BaseDir    string
 	Pack       *Pack
 	GameSide   Side
+	Retry      *RetryPolicy // how failed downloads are retried
+	Jobs       int          // concurrent downloads
+	HostJobs   int          // concurrent downloads from a single host, no limit if < 1
+	Deep       bool         // hash every file, instead of trusting unchanged stats
+	Store      *Store       // downloads shared with other installations, none if nil
+	Rewrite    RewriteRules // mirrors downloads are redirected to
+	ProxyCache string       // url of a caching proxy downloads are tried from first, none if empty
 	httpClient *http.Client
+	options    map[string]bool
+	// curseFiles are the curseforge files resolved for the install, by id
+	curseFiles   map[int]*CurseFile
+	curseUpdated bool // a download url of curseFiles was replaced
+	curseMu      sync.Mutex
+	// partialLocks guards partial downloads, keyed by their path
+	partialLocks sync.Map
 }
 
 // NewLocalInstaller creates a new installer for the given pack in the specified directory
@@ -62,10 +103,45 @@ func NewLocalInstaller(p *Pack, dir string, gameSide Side) (*LocalInstaller, err
 		BaseDir:    abs,
 		Pack:       p,
 		GameSide:   gameSide,
-		httpClient: http.DefaultClient,
+		Retry:      cmp.Or(p.retry, DefaultRetryPolicy()),
+		Jobs:       DefaultJobs,
+		HostJobs:   DefaultHostJobs,
+		Rewrite:    p.rewrite,
+		httpClient: cmp.Or(p.httpClient, http.DefaultClient),
 	}, nil
 }
 
+// LoadLocalInstaller returns an installer for the pack that was last installed
+// in dir, as recorded in its install state.
+func LoadLocalInstaller(dir string) (*LocalInstaller, error) {
+	i, err := NewLocalInstaller(&Pack{}, dir, Side_Both)
+	if err != nil {
+		return nil, err
+	}
+
+	mods, err := i.getInstalledMods()
+	if err != nil {
+		return nil, err
+	}
+	if mods == nil {
+		return nil, fmt.Errorf("no pack installed in %s", i.BaseDir)
+	}
+	state, err := i.GetInstallState()
+	if err != nil {
+		return nil, err
+	}
+
+	i.Pack = &Pack{
+		Name:     state.Name,
+		Author:   state.Author,
+		Version:  state.Version,
+		Revision: state.Revision,
+		Versions: state.Versions,
+		Mods:     mods,
+	}
+	return i, nil
+}
+
 func (i *LocalInstaller) saveCache(name string, v any) error {
 	p := filepath.Join(i.BaseDir, ".pw-install", fmt.Sprintf("%s.json", name))
 	data, err := json.MarshalIndent(v, "", "  ")
@@ -100,7 +176,11 @@ func (i *LocalInstaller) restoreCache(name string, v any) error {
 }
 
 func (i *LocalInstaller) setInstalledMods() error {
-	return i.saveCache("installed", i.Pack.Mods)
+	mods, err := i.filterMods()
+	if err != nil {
+		return err
+	}
+	return i.saveCache("installed", mods)
 }
 
 func (i *LocalInstaller) getInstalledMods() ([]*Mod, error) {
@@ -112,47 +192,219 @@ func (i *LocalInstaller) getInstalledMods() ([]*Mod, error) {
 	return mods, nil
 }
 
-func (i *LocalInstaller) checkIntegrity(m *Mod) (bool, error) {
+func (i *LocalInstaller) setInstallState() error {
+	return i.saveCache("state", &InstallState{
+		Name:      i.Pack.Name,
+		Author:    i.Pack.Author,
+		Version:   i.Pack.Version,
+		Revision:  i.Pack.Revision,
+		Versions:  i.Pack.Versions,
+		PackHash:  i.Pack.packHash,
+		IndexHash: i.Pack.indexHash,
+		Side:      i.GameSide,
+	})
+}
+
+// GetInstallState returns the state recorded by the last installation.
+// It is empty when nothing has been installed yet.
+func (i *LocalInstaller) GetInstallState() (*InstallState, error) {
+	var state = &InstallState{}
+	err := i.restoreCache("state", state)
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// checkIntegrity returns the stat of the file of m if it exists and matches
+// the hash of m, or nil. Files recorded in stats with an unchanged stat are
+// not hashed again.
+func (i *LocalInstaller) checkIntegrity(m *Mod, stats map[string]fileStat) (*fileStat, error) {
 	// existence
 	p := filepath.Join(i.BaseDir, m.Path)
-	stat, err := os.Stat(p)
+	fi, err := os.Stat(p)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return false, nil
-		}
-		if stat.IsDir() {
-			return false, nil
+			return nil, nil
 		}
-		return false, err
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	// preserved files may have been edited by the user
+	if m.Preserve {
+		return &fileStat{}, nil
+	}
+
+	if s, ok := stats[m.Path]; ok && !i.Deep && s.matches(fi, m) {
+		return &s, nil
 	}
 
 	// hash
-	data, err := os.ReadFile(p)
+	sums, _, err := hashFile(p, m.HashFormat)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if !strings.EqualFold(sums[m.HashFormat], m.Hash) {
+		return nil, nil
 	}
-	valid, err := MatchHash(data, m.HashFormat, m.Hash)
+	s := newFileStat(fi, m)
+	return &s, nil
+}
+
+// Verify checks the installed files against their hashes, and returns those
+// that are missing or changed. Unless Deep is set, files that were verified
+// before and whose stat did not change since are not hashed again.
+func (i *LocalInstaller) Verify() ([]*Mod, error) {
+	mods, err := i.getInstalledMods()
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	stats, err := i.getFileStats()
+	if err != nil {
+		return nil, err
 	}
 
-	return valid, nil
+	var (
+		failed []*Mod
+		mut    sync.Mutex
+	)
+	eg := &errgroup.Group{}
+	eg.SetLimit(runtime.NumCPU())
+	for _, m := range mods {
+		m := m // capture for closure
+		eg.Go(func() error {
+			s, err := i.checkIntegrity(m, stats)
+			if err != nil {
+				return fmt.Errorf("check integrity: %w", err)
+			}
+			if s == nil {
+				mut.Lock()
+				failed = append(failed, m)
+				mut.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	slices.SortFunc(failed, func(a, b *Mod) int { return cmp.Compare(a.Path, b.Path) })
+	return failed, nil
 }
 
-// GetUpdates determines which mods need to be added, removed, or are unchanged
-func (i *LocalInstaller) GetUpdates() (*Updates, error) {
-	installed, err := i.getInstalledMods()
+func (i *LocalInstaller) setOptions() error {
+	if i.options == nil {
+		return nil
+	}
+	return i.saveCache("options", i.options)
+}
+
+func (i *LocalInstaller) getOptions() (map[string]bool, error) {
+	if i.options != nil {
+		return i.options, nil
+	}
+	var options = make(map[string]bool)
+	err := i.restoreCache("options", &options)
 	if err != nil {
 		return nil, err
 	}
+	i.options = options
+	return options, nil
+}
+
+// SetOption records whether the optional mod with the given name should be
+// installed. The choice is persisted by the next Install and overrides the
+// default of the mod from then on.
+func (i *LocalInstaller) SetOption(name string, enabled bool) error {
+	options, err := i.getOptions()
+	if err != nil {
+		return err
+	}
+	for _, m := range i.Pack.Mods {
+		if m.IsOptional() && strings.EqualFold(m.OptionName(), name) {
+			options[m.OptionName()] = enabled
+			return nil
+		}
+	}
+	return fmt.Errorf("no optional mod named %q", name)
+}
+
+// IsEnabled returns whether the mod is selected for installation,
+// which is always the case for mods that are not optional.
+func (i *LocalInstaller) IsEnabled(m *Mod) (bool, error) {
+	if !m.IsOptional() {
+		return true, nil
+	}
+	options, err := i.getOptions()
+	if err != nil {
+		return false, err
+	}
+	if enabled, ok := options[m.OptionName()]; ok {
+		return enabled, nil
+	}
+	return m.Option.Default, nil
+}
+
+// InstallAll installs every file of the pack for the game side, including
+// optional mods that are not selected, and drops the files of the other side
+// from the pack. Exports use it to read a whole pack from disk, while its
+// optional mods keep their defaults.
+func (i *LocalInstaller) InstallAll(ctx context.Context) error {
+	var mods []*Mod
+	for _, m := range i.Pack.Mods {
+		if !i.GameSide.ShouldInstall(m.Side) {
+			continue
+		}
+		if m.IsOptional() {
+			if err := i.SetOption(m.OptionName(), true); err != nil {
+				return err
+			}
+		}
+		mods = append(mods, m)
+	}
+	if _, err := i.Install(ctx); err != nil {
+		return err
+	}
 
-	// Filter mods based on game side
+	p := *i.Pack
+	p.Mods = mods
+	i.Pack = &p
+	return nil
+}
+
+// filterMods returns the mods of the pack that are installed for the game
+// side and the selection of optional mods
+func (i *LocalInstaller) filterMods() ([]*Mod, error) {
 	filteredMods := make([]*Mod, 0, len(i.Pack.Mods))
 	for _, m := range i.Pack.Mods {
-		if i.GameSide.ShouldInstall(m.Side) {
+		if !i.GameSide.ShouldInstall(m.Side) {
+			continue
+		}
+		enabled, err := i.IsEnabled(m)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
 			filteredMods = append(filteredMods, m)
 		}
 	}
+	return filteredMods, nil
+}
+
+// GetUpdates determines which mods need to be added, removed, or are unchanged
+func (i *LocalInstaller) GetUpdates() (*Updates, error) {
+	installed, err := i.getInstalledMods()
+	if err != nil {
+		return nil, err
+	}
+
+	filteredMods, err := i.filterMods()
+	if err != nil {
+		return nil, err
+	}
 
 	a, r, u := diffSliceFunc(installed, filteredMods, func(a, b *Mod) int {
 		res := cmp.Compare(a.Path, b.Path)
@@ -161,6 +413,12 @@ func (i *LocalInstaller) GetUpdates() (*Updates, error) {
 		}
 		return res
 	})
+
+	// a changed file is both added and removed, it must not be deleted
+	// after the new version has been written
+	r = slices.DeleteFunc(r, func(m *Mod) bool {
+		return slices.ContainsFunc(a, func(n *Mod) bool { return n.Path == m.Path })
+	})
 	return &Updates{
 		Added:     a,
 		Removed:   r,
@@ -168,39 +426,142 @@ func (i *LocalInstaller) GetUpdates() (*Updates, error) {
 	}, nil
 }
 
-// InstallMod installs or updates a single mod
+// InstallMod installs or updates a single mod. Downloads are streamed to
+// disk, so memory use does not depend on the size of the file. A download
+// not matching its hash is tried once more, as it may have been corrupted
+// on the way.
 func (i *LocalInstaller) InstallMod(ctx context.Context, m *Mod) error {
-	var (
-		data []byte
-		err  error
-	)
+	err := i.installMod(ctx, m)
+	if errors.Is(err, errHashMismatch) {
+		err = i.installMod(ctx, m)
+	}
+	return err
+}
+
+func (i *LocalInstaller) installMod(ctx context.Context, m *Mod) error {
+	p := filepath.Join(i.BaseDir, m.Path)
+	if m.Downloads.Type == DL_Source {
+		if i.Pack.source == nil {
+			return fmt.Errorf("pack has no source to read %s from", m.Downloads.Data)
+		}
+		// files of a pack served over http are resumed like other downloads
+		if s, ok := i.Pack.source.(*httpSource); ok {
+			return i.fetchFile(ctx, i.Retry, s.urls(m.Downloads.Data), m, p)
+		}
+		return copyValidFile(ctx, i.Pack.source, m.Downloads.Data, p, m.HashFormat, m.Hash)
+	}
+
+	// preserved files are left out of the store, as they may be edited
+	useStore := i.Store != nil && !m.Preserve
+	if useStore {
+		if ok, err := i.Store.place(m, p); ok || err != nil {
+			return err
+		}
+	}
+
+	if err := i.downloadMod(ctx, m, p); err != nil {
+		return err
+	}
+	if useStore {
+		if err := i.Store.put(m, p); err != nil {
+			return fmt.Errorf("add to store: %w", err)
+		}
+	}
+	return nil
+}
+
+// downloadMod downloads the file of m to p, from the caching proxy if there
+// is one and it has the file.
+func (i *LocalInstaller) downloadMod(ctx context.Context, m *Mod, p string) error {
+	if i.ProxyCache != "" {
+		// a single attempt, an unavailable proxy must not hold up the install
+		u := strings.TrimSuffix(i.ProxyCache, "/") + "/objects/" + m.HashFormat + "/" + strings.ToLower(m.Hash)
+		err := i.fetchFile(ctx, nil, []string{u}, m, p)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	var u string
 	switch m.Downloads.Type {
 	case DL_Url:
-		data, err = httpGetValidBytes(ctx, i.httpClient, m.Downloads.Data, m.HashFormat, m.Hash)
-		if err != nil {
-			return err
+		var urls []string
+		for _, u := range append([]string{m.Downloads.Data}, m.Downloads.Mirrors...) {
+			urls = append(urls, i.Rewrite.urls(u)...)
 		}
+		return i.fetchFile(ctx, i.Retry, urls, m, p)
 	case DL_Curseforge:
 		cfData, err := ParseCfData(m.Downloads.Data)
 		if err != nil {
 			return err
 		}
-		u, err := DefaultCurseClient.GetDownloadUrl(ctx, cfData)
-		if err != nil {
-			return err
+		if cached := i.curseUrl(cfData.FileID); cached != "" {
+			err := i.downloadFile(ctx, cached, m, p)
+			if err == nil || ctx.Err() != nil {
+				return err
+			}
+			// the cached url may be outdated, e.g. when curseforge moved its cdn
+			u, uerr := i.curseClient().GetDownloadUrl(ctx, cfData)
+			if uerr != nil || u == "" || u == cached {
+				return err
+			}
+			i.updateCurseUrl(cfData.FileID, u)
+			return i.downloadFile(ctx, u, m, p)
 		}
-		data, err = httpGetValidBytes(ctx, i.httpClient, u, m.HashFormat, m.Hash)
+		// some projects hide their download urls from the file list
+		u, err = i.curseClient().GetDownloadUrl(ctx, cfData)
 		if err != nil {
 			return err
 		}
+	default:
+		return fmt.Errorf("unsupported download type %q: %s", m.Downloads.Type, m.Path)
 	}
+	return i.downloadFile(ctx, u, m, p)
+}
 
-	p := filepath.Join(i.BaseDir, m.Path)
-	err = os.MkdirAll(filepath.Dir(p), os.ModePerm)
-	if err != nil {
-		return err
+// curseClient returns the curseforge client of the installer, sharing its
+// http client and retry policy.
+func (i *LocalInstaller) curseClient() *CurseClient {
+	return DefaultCurseClient.WithHttpClient(i.httpClient).WithRetryPolicy(i.Retry)
+}
+
+// downloadHost returns the host m is downloaded from, for limiting the
+// connections to it. Files read from a local source have no host.
+func (i *LocalInstaller) downloadHost(m *Mod) string {
+	switch m.Downloads.Type {
+	case DL_Url:
+		if u, err := url.Parse(i.Rewrite.urls(m.Downloads.Data)[0]); err == nil {
+			return u.Host
+		}
+	case DL_Curseforge:
+		if cfData, err := ParseCfData(m.Downloads.Data); err == nil {
+			if cached := i.curseUrl(cfData.FileID); cached != "" {
+				if u, err := url.Parse(i.Rewrite.urls(cached)[0]); err == nil {
+					return u.Host
+				}
+			}
+		}
+		// resolved to a cdn url right before downloading
+		return "curseforge"
+	case DL_Source:
+		if s, ok := i.Pack.source.(*httpSource); ok {
+			return s.base.Host
+		}
 	}
-	return os.WriteFile(p, data, os.ModePerm)
+	return ""
+}
+
+// sizeHints returns the sizes of the installed files by the name of their mod.
+// Metafiles have no size, the one of the version installed before is close
+// enough to order downloads by.
+func sizeHints(installed []*Mod, stats map[string]fileStat) map[string]int64 {
+	hints := make(map[string]int64)
+	for _, m := range installed {
+		if s, ok := stats[m.Path]; ok {
+			hints[m.OptionName()] = s.Size
+		}
+	}
+	return hints
 }
 
 // Install executes installation and update of the modpack
@@ -211,20 +572,31 @@ func (i *LocalInstaller) Install(ctx context.Context) (*Updates, error) {
 		return nil, fmt.Errorf("check updates: %w", err)
 	}
 
+	oldStats, err := i.getFileStats()
+	if err != nil {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+	// stats of the files verified by this run
+	stats := make(map[string]fileStat)
+
 	mut := sync.Mutex{}
-	eg, ctx := errgroup.WithContext(ctx)
+	// hashing is bound by the CPU, downloads are scheduled separately
+	eg := &errgroup.Group{}
 	eg.SetLimit(runtime.NumCPU())
 
 	for _, m := range update.Unchanged {
 		m := m // capture for closure
 		eg.Go(func() error {
-			ok, err := i.checkIntegrity(m)
+			s, err := i.checkIntegrity(m, oldStats)
 			if err != nil {
 				return fmt.Errorf("check integrity: %w", err)
 			}
 			mut.Lock()
-			if ok {
+			if s != nil && m.Preserve {
+				result.Preserved = append(result.Preserved, m)
+			} else if s != nil {
 				result.Unchanged = append(result.Unchanged, m)
+				stats[m.Path] = *s
 			} else {
 				update.Added = append(update.Added, m)
 			}
@@ -236,23 +608,68 @@ func (i *LocalInstaller) Install(ctx context.Context) (*Updates, error) {
 		return nil, err
 	}
 
+	if i.Store != nil {
+		// registered before downloading, so that GC keeps the new objects
+		if err := i.saveCache("pending", update.Added); err != nil {
+			return nil, fmt.Errorf("save cache: %w", err)
+		}
+		if err := i.Store.register(i.BaseDir); err != nil {
+			return nil, fmt.Errorf("register in store: %w", err)
+		}
+	}
+
+	if err := i.resolveCurseFiles(ctx, update.Added); err != nil {
+		return nil, err
+	}
+
+	installed, err := i.getInstalledMods()
+	if err != nil {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+	hints := sizeHints(installed, oldStats)
+
+	var jobs = make([]downloadJob, 0, len(update.Added))
 	for _, m := range update.Added {
 		m := m // capture for closure
-		eg.Go(func() error {
+		size := cmp.Or(m.Size, hints[m.OptionName()])
+		jobs = append(jobs, downloadJob{host: i.downloadHost(m), size: size, run: func(ctx context.Context) error {
+			if m.Preserve {
+				_, err := os.Stat(filepath.Join(i.BaseDir, m.Path))
+				if err == nil {
+					mut.Lock()
+					result.Preserved = append(result.Preserved, m)
+					mut.Unlock()
+					return nil
+				}
+				if !os.IsNotExist(err) {
+					return fmt.Errorf("install mod: %w", err)
+				}
+			}
+
 			err := i.InstallMod(ctx, m)
 			if err != nil {
 				return fmt.Errorf("install mod: %w", err)
 			}
+			fi, err := os.Stat(filepath.Join(i.BaseDir, m.Path))
+			if err != nil {
+				return fmt.Errorf("install mod: %w", err)
+			}
 			mut.Lock()
 			result.Added = append(result.Added, m)
+			if !m.Preserve {
+				stats[m.Path] = newFileStat(fi, m)
+			}
 			mut.Unlock()
 			return nil
-		})
+		}})
 	}
-	if err := eg.Wait(); err != nil {
+	sched := &downloadScheduler{jobs: i.Jobs, hostJobs: i.HostJobs}
+	if err := sched.run(ctx, jobs); err != nil {
 		return nil, err
 	}
 
+	eg = &errgroup.Group{}
+	eg.SetLimit(runtime.NumCPU())
 	for _, m := range update.Removed {
 		m := m // capture for closure
 		eg.Go(func() error {
@@ -273,9 +690,36 @@ func (i *LocalInstaller) Install(ctx context.Context) (*Updates, error) {
 		return nil, err
 	}
 
+	// every download finished, what is left over is stale
+	err = os.RemoveAll(filepath.Join(i.BaseDir, ".pw-install", "partial"))
+	if err != nil {
+		return nil, fmt.Errorf("remove partial downloads: %w", err)
+	}
+
 	err = i.setInstalledMods()
 	if err != nil {
 		return nil, fmt.Errorf("save cache: %w", err)
 	}
+	if i.curseUpdated {
+		if err := i.saveCache("curseforge", i.curseFiles); err != nil {
+			return nil, fmt.Errorf("save cache: %w", err)
+		}
+	}
+	err = i.setFileStats(stats)
+	if err != nil {
+		return nil, fmt.Errorf("save cache: %w", err)
+	}
+	err = i.setInstallState()
+	if err != nil {
+		return nil, fmt.Errorf("save state: %w", err)
+	}
+	err = i.setOptions()
+	if err != nil {
+		return nil, fmt.Errorf("save options: %w", err)
+	}
+	err = os.Remove(filepath.Join(i.BaseDir, ".pw-install", "pending.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("save cache: %w", err)
+	}
 	return result, nil
 }
@@ -0,0 +1,367 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// newTestPack returns a pack whose files are served from memory.
+func newTestPack(files map[string]string) *Pack {
+	fsys := fstest.MapFS{}
+	p := &Pack{Name: "test", source: &fsSource{fsys: fsys}}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		p.Mods = append(p.Mods, &Mod{
+			Path:       name,
+			Name:       name,
+			Hash:       sha256Hex([]byte(content)),
+			HashFormat: "sha256",
+			Side:       Side_Both,
+			Downloads:  &Download{Type: DL_Source, Data: name},
+		})
+	}
+	return p
+}
+
+func findMod(p *Pack, path string) *Mod {
+	for _, m := range p.Mods {
+		if m.Path == path {
+			return m
+		}
+	}
+	return nil
+}
+
+func exists(t *testing.T, p string) bool {
+	t.Helper()
+	_, err := os.Stat(p)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestLocalInstaller_Options(t *testing.T) {
+	dir := t.TempDir()
+	pack := newTestPack(map[string]string{
+		"mods/a.jar": "a",
+		"mods/b.jar": "b",
+	})
+	findMod(pack, "mods/a.jar").Option = &ModOption{Default: false}
+	findMod(pack, "mods/b.jar").Option = &ModOption{Default: true}
+
+	install := func(options map[string]bool) {
+		t.Helper()
+		inst, err := NewLocalInstaller(pack, dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, enabled := range options {
+			if err := inst.SetOption(name, enabled); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := inst.Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// defaults
+	install(nil)
+	if exists(t, filepath.Join(dir, "mods", "a.jar")) || !exists(t, filepath.Join(dir, "mods", "b.jar")) {
+		t.Fatal("optional mods did not follow their default")
+	}
+
+	// explicit choices, names are case insensitive
+	install(map[string]bool{"MODS/A.JAR": true, "mods/b.jar": false})
+	if !exists(t, filepath.Join(dir, "mods", "a.jar")) || exists(t, filepath.Join(dir, "mods", "b.jar")) {
+		t.Fatal("optional mods did not follow the selection")
+	}
+
+	// choices are persisted
+	install(nil)
+	if !exists(t, filepath.Join(dir, "mods", "a.jar")) || exists(t, filepath.Join(dir, "mods", "b.jar")) {
+		t.Fatal("selection was not persisted")
+	}
+
+	inst, err := NewLocalInstaller(pack, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inst.SetOption("unknown", true); err == nil {
+		t.Error("SetOption() accepted an unknown mod")
+	}
+}
+
+func TestLocalInstaller_Update(t *testing.T) {
+	dir := t.TempDir()
+	install := func(files map[string]string) *Updates {
+		t.Helper()
+		inst, err := NewLocalInstaller(newTestPack(files), dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := inst.Install(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	install(map[string]string{"config/a.toml": "v1", "config/b.toml": "v1"})
+	u := install(map[string]string{"config/a.toml": "v2"})
+
+	// changed files are replaced, not deleted
+	data, err := os.ReadFile(filepath.Join(dir, "config", "a.toml"))
+	if err != nil || string(data) != "v2" {
+		t.Errorf("changed file = %q, %v, want %q", data, err, "v2")
+	}
+	if exists(t, filepath.Join(dir, "config", "b.toml")) {
+		t.Error("file removed from the pack is still installed")
+	}
+	if len(u.Added) != 1 || len(u.Removed) != 1 || u.Removed[0].Path != "config/b.toml" {
+		t.Errorf("updates = %v", u)
+	}
+}
+
+func TestLocalInstaller_Preserve(t *testing.T) {
+	dir := t.TempDir()
+	install := func(files map[string]string) *Updates {
+		t.Helper()
+		pack := newTestPack(files)
+		findMod(pack, "config/a.toml").Preserve = true
+		inst, err := NewLocalInstaller(pack, dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := inst.Install(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	install(map[string]string{"config/a.toml": "v1", "config/b.toml": "v1"})
+	writeTestFile(t, filepath.Join(dir, "config", "a.toml"), "edited")
+
+	// updates overwrite plain files, but leave edited preserved ones alone
+	u := install(map[string]string{"config/a.toml": "v2", "config/b.toml": "v2"})
+	if got := read("config/a.toml"); got != "edited" {
+		t.Errorf("preserved file = %q, want %q", got, "edited")
+	}
+	if got := read("config/b.toml"); got != "v2" {
+		t.Errorf("updated file = %q, want %q", got, "v2")
+	}
+	if len(u.Preserved) != 1 {
+		t.Errorf("Preserved = %d files, want 1", len(u.Preserved))
+	}
+
+	// preserved files are still written when missing
+	os.Remove(filepath.Join(dir, "config", "a.toml"))
+	install(map[string]string{"config/a.toml": "v2", "config/b.toml": "v2"})
+	if got := read("config/a.toml"); got != "v2" {
+		t.Errorf("missing preserved file = %q, want %q", got, "v2")
+	}
+}
+
+func TestLocalInstaller_Verify(t *testing.T) {
+	dir := t.TempDir()
+	pack := newTestPack(map[string]string{
+		"mods/a.jar": "a",
+		"mods/b.jar": "b",
+	})
+	inst, err := NewLocalInstaller(pack, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	verify := func(deep bool) []string {
+		t.Helper()
+		inst.Deep = deep
+		failed, err := inst.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, m := range failed {
+			paths = append(paths, m.Path)
+		}
+		return paths
+	}
+	if failed := verify(true); len(failed) != 0 {
+		t.Fatalf("Verify() of a fresh install = %v", failed)
+	}
+
+	// corrupt a.jar in place, keeping its stat
+	p := filepath.Join(dir, "mods", "a.jar")
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("x"))
+	f.Close()
+	if err := os.Chtimes(p, fi.ModTime(), fi.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(dir, "mods", "b.jar"))
+
+	if failed := verify(false); !slices.Equal(failed, []string{"mods/b.jar"}) {
+		t.Errorf("Verify() = %v, want only the removed file", failed)
+	}
+	if failed := verify(true); !slices.Equal(failed, []string{"mods/a.jar", "mods/b.jar"}) {
+		t.Errorf("deep Verify() = %v, want both files", failed)
+	}
+
+	// a deep install repairs what the stat cache hides
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if failed := verify(true); len(failed) != 0 {
+		t.Errorf("Verify() after a deep install = %v", failed)
+	}
+}
+
+func TestLocalInstaller_InstallModUrl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("jar"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	inst, err := NewLocalInstaller(&Pack{}, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Mod{
+		Path:       "mods/a.jar",
+		Hash:       sha256Hex([]byte("jar")),
+		HashFormat: "sha256",
+		Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/a.jar"},
+	}
+	if err := inst.InstallMod(context.Background(), m); err != nil {
+		t.Fatalf("InstallMod() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "mods", "a.jar"))
+	if err != nil || string(data) != "jar" {
+		t.Errorf("installed content = %q, %v", data, err)
+	}
+
+	// a mismatched download leaves neither the file nor a temporary file behind
+	m.Path = "mods/b.jar"
+	m.Hash = sha256Hex([]byte("other"))
+	if err := inst.InstallMod(context.Background(), m); err == nil {
+		t.Fatal("InstallMod() accepted a mismatched download")
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "mods"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("mods contains %d files, want 1", len(entries))
+	}
+}
+
+func TestLocalInstaller_InstallModHashRetry(t *testing.T) {
+	var reqs int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs++
+		if reqs == 1 {
+			w.Write([]byte("corrupted"))
+			return
+		}
+		w.Write([]byte("jar"))
+	}))
+	defer srv.Close()
+
+	inst, err := NewLocalInstaller(&Pack{}, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = inst.InstallMod(context.Background(), &Mod{
+		Path:       "mods/a.jar",
+		Hash:       sha256Hex([]byte("jar")),
+		HashFormat: "sha256",
+		Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/a.jar"},
+	})
+	if err != nil {
+		t.Fatalf("InstallMod() error = %v", err)
+	}
+	if reqs != 2 {
+		t.Errorf("sent %d requests, want 2", reqs)
+	}
+}
+
+func TestLocalInstaller_InstallSizeHints(t *testing.T) {
+	var order []string
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/")))
+	}))
+	defer srv.Close()
+
+	// metafiles have no size, like these
+	newMods := func(small, large string) []*Mod {
+		var mods []*Mod
+		for _, name := range []string{small, large} {
+			mods = append(mods, &Mod{
+				Path:       "mods/" + name + ".jar",
+				Name:       strings.TrimRight(name, "0123456789"),
+				Hash:       sha256Hex([]byte(name)),
+				HashFormat: "sha256",
+				Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/" + name},
+			})
+		}
+		return mods
+	}
+	dir := t.TempDir()
+	install := func(mods []*Mod) {
+		t.Helper()
+		inst, err := NewLocalInstaller(&Pack{Mods: mods}, dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst.Jobs = 1
+		if _, err := inst.Install(context.Background()); err != nil {
+			t.Fatalf("Install() error = %v", err)
+		}
+	}
+
+	install(newMods("small1", "large-----------1"))
+	order = nil
+	// updates are ordered by the size of the version installed before
+	install(newMods("small2", "large-----------2"))
+	if want := []string{"/large-----------2", "/small2"}; !slices.Equal(order, want) {
+		t.Errorf("download order = %v, want %v", order, want)
+	}
+}
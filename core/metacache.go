@@ -0,0 +1,88 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// metafileCache stores metafiles by the hash the index gives for them, so a
+// metafile is only fetched again when its hash in the index changes.
+type metafileCache struct {
+	dir string
+
+	mu   sync.Mutex
+	used map[string]bool // names read or written since the cache was created
+}
+
+func newMetafileCache(dir string) *metafileCache {
+	return &metafileCache{dir: dir, used: make(map[string]bool)}
+}
+
+func (c *metafileCache) name(hashFormat, hash string) (string, bool) {
+	name := hashFormat + "-" + strings.ToLower(hash)
+	if hash == "" || strings.ContainsAny(name, `/\.`) {
+		return "", false
+	}
+	c.mu.Lock()
+	c.used[name] = true
+	c.mu.Unlock()
+	return name, true
+}
+
+// get returns the cached metafile with the given hash. The data is verified
+// again, so a damaged entry is treated like a missing one.
+func (c *metafileCache) get(hashFormat, hash string) ([]byte, bool) {
+	name, ok := c.name(hashFormat, hash)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, false
+	}
+	if valid, err := MatchHash(data, hashFormat, hash); err != nil || !valid {
+		return nil, false
+	}
+	return data, true
+}
+
+// put stores a verified metafile, failing to do so is no error as the cache
+// is only an optimization.
+func (c *metafileCache) put(hashFormat, hash string, data []byte) {
+	name, ok := c.name(hashFormat, hash)
+	if !ok {
+		return
+	}
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return
+	}
+	os.WriteFile(filepath.Join(c.dir, name), data, 0o644)
+}
+
+// prune removes every metafile that was not used since the cache was created.
+func (c *metafileCache) prune() error {
+	entries, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		if !c.used[e.Name()] {
+			os.Remove(filepath.Join(c.dir, e.Name()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// https://support.modrinth.com/en/articles/8802351-modrinth-modpack-format-mrpack
+const mrpackIndexFile = "modrinth.index.json"
+
+type MrpackIndex struct {
+	FormatVersion int               `json:"formatVersion"`
+	Game          string            `json:"game"`
+	VersionId     string            `json:"versionId"`
+	Name          string            `json:"name"`
+	Summary       string            `json:"summary,omitempty"`
+	Files         []MrpackFile      `json:"files"`
+	Dependencies  map[string]string `json:"dependencies"`
+}
+
+type MrpackFile struct {
+	Path      string            `json:"path"`
+	Hashes    map[string]string `json:"hashes"`
+	Env       *MrpackEnv        `json:"env,omitempty"`
+	Downloads []string          `json:"downloads"`
+	FileSize  int64             `json:"fileSize"`
+}
+
+type MrpackEnv struct {
+	Client string `json:"client"`
+	Server string `json:"server"`
+}
+
+const (
+	mrpackRequired    = "required"
+	mrpackOptional    = "optional"
+	mrpackUnsupported = "unsupported"
+)
+
+// mrpackDependencies maps mrpack dependency ids onto packwiz version keys.
+var mrpackDependencies = map[string]string{
+	"minecraft":     "minecraft",
+	"forge":         "forge",
+	"neoforge":      "neoforge",
+	"fabric-loader": "fabric",
+	"quilt-loader":  "quilt",
+}
+
+// mrpackHashes lists the hashes of mrpack files in the order they are used,
+// sha512 is the primary hash of modrinth and sha1 only a fallback.
+var mrpackHashes = []string{"sha512", "sha1"}
+
+// mrpackOverrides maps the override folders of a mrpack onto the side
+// they are installed for, in the order they are applied.
+var mrpackOverrides = []struct {
+	Dir  string
+	Side Side
+}{
+	{"overrides", Side_Both},
+	{"client-overrides", Side_Client},
+	{"server-overrides", Side_Server},
+}
+
+// IsMrpackUrl returns whether u points at a Modrinth modpack.
+func IsMrpackUrl(u *url.URL) bool {
+	return strings.HasSuffix(strings.ToLower(u.Path), ".mrpack")
+}
+
+// LoadMrpack reads the Modrinth modpack at the file or http(s) URL u.
+// If hash is not empty, the mrpack file is verified against it. The options
+// configure how the mrpack is fetched, like for a Repository.
+func LoadMrpack(ctx context.Context, u *url.URL, hashFormat, hash string, opts ...RepoOptFn) (*Pack, error) {
+	repo := NewRepository(u, hashFormat, hash, opts...)
+	f, err := fetchArchive(ctx, u, repo.httpClient, repo.retry, repo.rewrite)
+	if err != nil {
+		return nil, err
+	}
+	if hash != "" {
+		valid, err := f.matchHash(hashFormat, hash)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if !valid {
+			f.Close()
+			return nil, fmt.Errorf("mrpack hash mismatched: %s", RedactUrl(u.String()))
+		}
+	}
+
+	a, err := openArchive(f, archiveZip)
+	if err != nil {
+		return nil, fmt.Errorf("read mrpack: %w", err)
+	}
+	pack, err := mrpackToPack(a)
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	pack.httpClient = repo.httpClient
+	pack.retry = repo.retry
+	pack.rewrite = repo.rewrite
+	return pack, nil
+}
+
+func mrpackToPack(a *archive) (*Pack, error) {
+	if _, ok := a.files[mrpackIndexFile]; !ok {
+		return nil, fmt.Errorf("%s not found in mrpack", mrpackIndexFile)
+	}
+	indexData, err := a.readFile(mrpackIndexFile)
+	if err != nil {
+		return nil, err
+	}
+	var index = new(MrpackIndex)
+	if err := json.Unmarshal(indexData, index); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", mrpackIndexFile, err)
+	}
+	if index.Game != "minecraft" {
+		return nil, fmt.Errorf("unsupported mrpack game: %q", index.Game)
+	}
+
+	var pack = &Pack{
+		Name:     index.Name,
+		Version:  index.VersionId,
+		Versions: make(map[string]string),
+		source:   &archiveSource{archive: a},
+	}
+	for id, v := range index.Dependencies {
+		if key, ok := mrpackDependencies[id]; ok {
+			pack.Versions[key] = v
+		}
+	}
+
+	var mods = make([]*Mod, 0, len(index.Files))
+	for _, f := range index.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f.Path)) {
+			return nil, fmt.Errorf("invalid mrpack file path: %q", f.Path)
+		}
+		if isMrpackUnsupported(f.Env) {
+			continue
+		}
+		if len(f.Downloads) == 0 {
+			return nil, fmt.Errorf("mrpack file has no downloads: %s", f.Path)
+		}
+		i := slices.IndexFunc(mrpackHashes, func(h string) bool {
+			return f.Hashes[h] != ""
+		})
+		if i == -1 {
+			return nil, fmt.Errorf("mrpack file has no supported hash: %s", f.Path)
+		}
+		hashFmt := mrpackHashes[i]
+
+		m := &Mod{
+			Path:       f.Path,
+			Name:       path.Base(f.Path),
+			Hash:       f.Hashes[hashFmt],
+			HashFormat: hashFmt,
+			Side:       mrpackEnvToSide(f.Env),
+			Downloads: &Download{
+				Type:    DL_Url,
+				Data:    f.Downloads[0],
+				Mirrors: f.Downloads[1:],
+			},
+			Size: f.FileSize,
+		}
+		if isMrpackOptional(f.Env) {
+			m.Option = &ModOption{Default: true}
+		}
+		mods = append(mods, m)
+	}
+
+	overrides, err := mrpackOverrideMods(a)
+	if err != nil {
+		return nil, err
+	}
+	pack.Mods = append(mods, overrides...)
+	return pack, nil
+}
+
+func mrpackEnvToSide(env *MrpackEnv) Side {
+	switch {
+	case env == nil:
+		return Side_Both
+	case env.Client == mrpackUnsupported && env.Server != mrpackUnsupported:
+		return Side_Server
+	case env.Server == mrpackUnsupported && env.Client != mrpackUnsupported:
+		return Side_Client
+	default:
+		return Side_Both
+	}
+}
+
+// mrpackOverrideMods returns the files of the override folders as mods read
+// from the mrpack itself. A file in a side specific folder replaces the one
+// from the common overrides for that side.
+func mrpackOverrideMods(a *archive) ([]*Mod, error) {
+	var byPath = make(map[string][]*Mod)
+	var paths []string
+	for _, o := range mrpackOverrides {
+		prefix := o.Dir + "/"
+		for name, e := range a.files {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			p := strings.TrimPrefix(name, prefix)
+			hash, err := a.hash(name, "sha256")
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := byPath[p]; !ok {
+				paths = append(paths, p)
+			}
+			if o.Side != Side_Both {
+				// narrow the common override down to the other side
+				for _, m := range byPath[p] {
+					if m.Side == Side_Both {
+						m.Side = otherSide(o.Side)
+					} else if m.Side == o.Side {
+						m.Side = ""
+					}
+				}
+			}
+			byPath[p] = append(byPath[p], &Mod{
+				Path:       p,
+				Hash:       hash,
+				HashFormat: "sha256",
+				Side:       o.Side,
+				Downloads: &Download{
+					Type: DL_Source,
+					Data: name,
+				},
+				Size: e.size,
+			})
+		}
+	}
+
+	slices.Sort(paths)
+	var mods []*Mod
+	for _, p := range paths {
+		for _, m := range byPath[p] {
+			if m.Side != "" {
+				mods = append(mods, m)
+			}
+		}
+	}
+	return mods, nil
+}
+
+func otherSide(s Side) Side {
+	switch s {
+	case Side_Client:
+		return Side_Server
+	case Side_Server:
+		return Side_Client
+	default:
+		return s
+	}
+}
+
+// isMrpackUnsupported returns whether a file is installed on neither side.
+func isMrpackUnsupported(env *MrpackEnv) bool {
+	return env != nil && env.Client == mrpackUnsupported && env.Server == mrpackUnsupported
+}
+
+// isMrpackOptional returns whether a file is optional on every side that
+// supports it.
+func isMrpackOptional(env *MrpackEnv) bool {
+	if env == nil || env.Client == mrpackRequired || env.Server == mrpackRequired {
+		return false
+	}
+	return env.Client == mrpackOptional || env.Server == mrpackOptional
+}
+
+func modToMrpackEnv(m *Mod) *MrpackEnv {
+	supported := mrpackRequired
+	if m.IsOptional() {
+		supported = mrpackOptional
+	}
+	switch m.Side {
+	case Side_Client:
+		return &MrpackEnv{Client: supported, Server: mrpackUnsupported}
+	case Side_Server:
+		return &MrpackEnv{Client: mrpackUnsupported, Server: supported}
+	default:
+		return &MrpackEnv{Client: supported, Server: supported}
+	}
+}
+
+func sideToMrpackOverrides(s Side) string {
+	for _, o := range mrpackOverrides {
+		if o.Side == s {
+			return o.Dir
+		}
+	}
+	return mrpackOverrides[0].Dir
+}
+
+// ExportMrpack writes the pack installed by inst as a Modrinth modpack.
+//
+// Mods downloaded from a URL become entries of the index, with hashes
+// computed from the installed files. Everything else, including files of
+// the pack itself and curseforge mods, is stored in the override folders.
+func ExportMrpack(w io.Writer, inst *LocalInstaller) error {
+	p := inst.Pack
+	var index = &MrpackIndex{
+		FormatVersion: 1,
+		Game:          "minecraft",
+		VersionId:     p.Version,
+		Name:          p.Name,
+		Files:         []MrpackFile{},
+		Dependencies:  make(map[string]string),
+	}
+	for id, key := range mrpackDependencies {
+		if v := p.Versions[key]; v != "" {
+			index.Dependencies[id] = v
+		}
+	}
+	if index.Dependencies["minecraft"] == "" {
+		return fmt.Errorf("mrpack export requires the minecraft version of the pack")
+	}
+	if index.VersionId == "" {
+		index.VersionId = "1.0.0"
+	}
+
+	var overrides []*Mod
+	for _, m := range p.Mods {
+		if m.Downloads == nil || m.Downloads.Type != DL_Url {
+			overrides = append(overrides, m)
+			continue
+		}
+
+		sums, size, err := hashFile(filepath.Join(inst.BaseDir, m.Path), "sha1", "sha512")
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", m.Path, err)
+		}
+		index.Files = append(index.Files, MrpackFile{
+			Path:      m.Path,
+			Hashes:    sums,
+			Env:       modToMrpackEnv(m),
+			Downloads: append([]string{m.Downloads.Data}, m.Downloads.Mirrors...),
+			FileSize:  size,
+		})
+	}
+
+	zw := zip.NewWriter(w)
+	iw, err := zw.Create(mrpackIndexFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(iw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(index); err != nil {
+		return err
+	}
+
+	for _, m := range overrides {
+		name := path.Join(sideToMrpackOverrides(m.Side), m.Path)
+		if err := addZipFile(zw, name, filepath.Join(inst.BaseDir, m.Path)); err != nil {
+			return fmt.Errorf("add %s: %w", m.Path, err)
+		}
+	}
+	return zw.Close()
+}
+
+func addZipFile(zw *zip.Writer, name string, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fw, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
@@ -0,0 +1,268 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_mrpackToPack(t *testing.T) {
+	index := `{
+  "formatVersion": 1,
+  "game": "minecraft",
+  "versionId": "1.0.0",
+  "name": "test",
+  "files": [
+    {
+      "path": "mods/client.jar",
+      "hashes": {"sha1": "aaaa", "sha512": "bbbb"},
+      "env": {"client": "required", "server": "unsupported"},
+      "downloads": ["https://cdn.modrinth.com/client.jar"],
+      "fileSize": 1
+    },
+    {
+      "path": "mods/both.jar",
+      "hashes": {"sha512": "cccc"},
+      "downloads": ["https://cdn.modrinth.com/both.jar"],
+      "fileSize": 1
+    },
+    {
+      "path": "mods/neither.jar",
+      "hashes": {"sha512": "dddd"},
+      "env": {"client": "unsupported", "server": "unsupported"},
+      "downloads": ["https://cdn.modrinth.com/neither.jar"],
+      "fileSize": 1
+    }
+  ],
+  "dependencies": {"minecraft": "1.20.1"}
+}`
+	files := map[string][]byte{
+		mrpackIndexFile:                      []byte(index),
+		"overrides/config/a.toml":            []byte("common"),
+		"client-overrides/config/a.toml":     []byte("client"),
+		"overrides/config/b.toml":            []byte("common"),
+		"server-overrides/server.properties": []byte("server"),
+	}
+
+	pack, err := mrpackToPack(newTestArchive(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Name != "test" || pack.Version != "1.0.0" {
+		t.Errorf("pack = %s %s, want test 1.0.0", pack.Name, pack.Version)
+	}
+
+	type want struct {
+		side       Side
+		hashFormat string
+		dlType     DLType
+	}
+	wants := map[string][]want{
+		"mods/client.jar":   {{Side_Client, "sha512", DL_Url}},
+		"mods/both.jar":     {{Side_Both, "sha512", DL_Url}},
+		"config/a.toml":     {{Side_Server, "sha256", DL_Source}, {Side_Client, "sha256", DL_Source}},
+		"config/b.toml":     {{Side_Both, "sha256", DL_Source}},
+		"server.properties": {{Side_Server, "sha256", DL_Source}},
+	}
+
+	got := map[string][]want{}
+	for _, m := range pack.Mods {
+		got[m.Path] = append(got[m.Path], want{m.Side, m.HashFormat, m.Downloads.Type})
+	}
+	for p, w := range wants {
+		g := got[p]
+		if len(g) != len(w) {
+			t.Errorf("%s: got %v, want %v", p, g, w)
+			continue
+		}
+		for i := range w {
+			if g[i] != w[i] {
+				t.Errorf("%s: got %v, want %v", p, g[i], w[i])
+			}
+		}
+	}
+	if len(got) != len(wants) {
+		t.Errorf("got %d paths, want %d", len(got), len(wants))
+	}
+}
+
+func Test_mrpackToPackInvalidPath(t *testing.T) {
+	index := `{"game": "minecraft", "files": [{"path": "../evil.jar", "hashes": {"sha1": "aa"}, "downloads": ["https://example.com"]}]}`
+	_, err := mrpackToPack(newTestArchive(t, map[string][]byte{mrpackIndexFile: []byte(index)}))
+	if err == nil {
+		t.Fatal("mrpackToPack() accepted a path outside the instance")
+	}
+}
+
+func TestExportMrpack(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "mods", "a.jar"), "jar")
+	writeTestFile(t, filepath.Join(dir, "config", "a.toml"), "config")
+
+	inst, err := NewLocalInstaller(&Pack{
+		Name:     "test",
+		Versions: map[string]string{"minecraft": "1.20.1", "fabric": "0.15.0"},
+		Mods: []*Mod{
+			{
+				Path:      "mods/a.jar",
+				Side:      Side_Client,
+				Downloads: &Download{Type: DL_Url, Data: "https://cdn.modrinth.com/a.jar"},
+			},
+			{
+				Path:      "config/a.toml",
+				Side:      Side_Server,
+				Downloads: &Download{Type: DL_Source, Data: "config/a.toml"},
+			},
+		},
+	}, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := ExportMrpack(buf, inst); err != nil {
+		t.Fatalf("ExportMrpack() error = %v", err)
+	}
+
+	a, err := readArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), archiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := a.readFile("server-overrides/config/a.toml"); err != nil || string(data) != "config" {
+		t.Errorf("override not exported, got %q, %v", data, err)
+	}
+
+	pack, err := mrpackToPack(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.Versions["fabric"] != "0.15.0" || pack.Versions["minecraft"] != "1.20.1" {
+		t.Errorf("Versions = %v", pack.Versions)
+	}
+	for _, m := range pack.Mods {
+		if m.Path != "mods/a.jar" {
+			continue
+		}
+		if m.Side != Side_Client || m.HashFormat != "sha512" || m.Hash != fmt.Sprintf("%x", sha512.Sum512([]byte("jar"))) {
+			t.Errorf("exported mod = %+v", m)
+		}
+		if m.Downloads.Data != "https://cdn.modrinth.com/a.jar" {
+			t.Errorf("exported download = %s", m.Downloads.Data)
+		}
+	}
+}
+
+func Test_mrpackToPackMirrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mirror/a.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("jar"))
+	}))
+	defer srv.Close()
+
+	index := fmt.Sprintf(`{
+  "formatVersion": 1,
+  "game": "minecraft",
+  "versionId": "1.0.0",
+  "name": "test",
+  "files": [
+    {
+      "path": "mods/a.jar",
+      "hashes": {"sha1": "%x"},
+      "downloads": ["%[2]s/gone/a.jar", "%[2]s/mirror/a.jar"],
+      "fileSize": 3
+    }
+  ],
+  "dependencies": {"minecraft": "1.20.1"}
+}`, sha1.Sum([]byte("jar")), srv.URL)
+	pack, err := mrpackToPack(newTestArchive(t, map[string][]byte{mrpackIndexFile: []byte(index)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first download url fails, the next one is used
+	inst, err := NewLocalInstaller(pack, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.Retry = nil
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(inst.BaseDir, "mods", "a.jar"))
+	if err != nil || string(data) != "jar" {
+		t.Errorf("installed content = %q, %v", data, err)
+	}
+}
+
+func TestExportMrpack_OptionalMods(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("jar " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	pack := newTestPack(map[string]string{"config/a.toml": "config"})
+	for _, name := range []string{"on.jar", "off.jar", "server.jar"} {
+		pack.Mods = append(pack.Mods, &Mod{
+			Path:       "mods/" + name,
+			Name:       name,
+			Hash:       sha256Hex([]byte("jar /" + name)),
+			HashFormat: "sha256",
+			Side:       Side_Both,
+			Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/" + name},
+		})
+	}
+	pack.Versions = map[string]string{"minecraft": "1.20.1"}
+	findMod(pack, "mods/on.jar").Option = &ModOption{Default: true}
+	findMod(pack, "mods/off.jar").Option = &ModOption{Default: false}
+	findMod(pack, "mods/server.jar").Side = Side_Server
+
+	inst, err := NewLocalInstaller(pack, t.TempDir(), Side_Client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.Retry = nil
+	if err := inst.InstallAll(context.Background()); err != nil {
+		t.Fatalf("InstallAll() error = %v", err)
+	}
+	buf := &bytes.Buffer{}
+	if err := ExportMrpack(buf, inst); err != nil {
+		t.Fatalf("ExportMrpack() error = %v", err)
+	}
+	a, err := readArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()), archiveZip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// optional mods are exported whatever their default, files of the
+	// other side are not
+	exported, err := mrpackToPack(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wants := map[string]bool{"mods/on.jar": true, "mods/off.jar": true, "config/a.toml": false}
+	if len(exported.Mods) != len(wants) {
+		t.Fatalf("got %d mods, want %d", len(exported.Mods), len(wants))
+	}
+	for _, m := range exported.Mods {
+		optional, ok := wants[m.Path]
+		if !ok || m.IsOptional() != optional {
+			t.Errorf("%s optional %v, want %v", m.Path, m.IsOptional(), optional)
+		}
+	}
+}
@@ -7,7 +7,10 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"path/filepath"
 	"slices"
@@ -25,26 +28,75 @@ const (
 
 	DL_Url        DLType = "url"
 	DL_Curseforge DLType = "curseforge"
+	// DL_Source files are read from the pack's own Source, Data is their
+	// path relative to pack.toml.
+	DL_Source DLType = "source"
 )
 
 type Download struct {
 	Type DLType `json:"type"`
 	Data string `json:"data"`
+	// Mirrors are more urls of a DL_Url download, tried in order when Data
+	// fails
+	Mirrors []string `json:"mirrors,omitempty"`
+}
+
+// ModOption describes a mod the user can choose not to install
+type ModOption struct {
+	Default     bool   `json:"default"`
+	Description string `json:"description,omitempty"`
 }
 
 type Mod struct {
-	Path       string    `json:"path"`
-	Hash       string    `json:"hash"`
-	HashFormat string    `json:"hashFormat"`
-	Side       Side      `json:"side,omitempty"`
-	Downloads  *Download `json:"download"`
+	Path       string     `json:"path"`
+	Name       string     `json:"name,omitempty"`
+	Hash       string     `json:"hash"`
+	HashFormat string     `json:"hashFormat"`
+	Side       Side       `json:"side,omitempty"`
+	Downloads  *Download  `json:"download"`
+	Size       int64      `json:"size,omitempty"` // 0 if unknown
+	Option     *ModOption `json:"option,omitempty"`
+	// Preserve files are only written when they do not exist yet,
+	// so that users can edit them
+	Preserve bool `json:"preserve,omitempty"`
+	// fileName is the name of the file in its metafile, which differs from
+	// the base of Path for aliased files
+	fileName string
+}
+
+// IsOptional returns whether the user can choose not to install the mod
+func (m *Mod) IsOptional() bool {
+	return m.Option != nil
+}
+
+// OptionName returns the name used to select an optional mod
+func (m *Mod) OptionName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.Path
 }
 
 type Pack struct {
-	Name    string `json:"name"`
-	Author  string `json:"author,omitempty"`
-	Version string `json:"version,omitempty"`
-	Mods    []*Mod `json:"files,omitempty"`
+	Name     string            `json:"name"`
+	Author   string            `json:"author,omitempty"`
+	Version  string            `json:"version,omitempty"`
+	Revision string            `json:"revision,omitempty"`
+	Versions map[string]string `json:"versions,omitempty"`
+	Mods     []*Mod            `json:"files,omitempty"`
+	source   Source
+	// packHash and indexHash identify the pack.toml and index the pack was
+	// loaded from, if any
+	packHash  string
+	indexHash string
+	// httpClient is the client the pack was loaded with, its installer
+	// uses it as well, like the retry policy and rewrite rules
+	httpClient *http.Client
+	retry      *RetryPolicy
+	rewrite    RewriteRules
+	// curseFiles are the curseforge files already looked up while loading
+	// the pack, by id
+	curseFiles map[int]*CurseFile
 }
 
 type CurseforgeData struct {
@@ -78,19 +130,26 @@ func ParseCfData(s string) (*CurseforgeData, error) {
 }
 
 func tomlToPack(
-	packUrl *url.URL,
+	src Source,
 	pack *PackToml,
 	index *IndexToml,
 	metafiles []*MetafileToml,
 ) (*Pack, error) {
 	var ppack = &Pack{
-		Name:    pack.Name,
-		Author:  pack.Author,
-		Version: pack.Version,
+		Name:     pack.Name,
+		Author:   pack.Author,
+		Version:  pack.Version,
+		Versions: pack.Versions,
+		source:   src,
 	}
 
 	var mods = make([]*Mod, 0, len(index.Files))
 	for _, f := range index.Files {
+		// aliased files are installed at the alias, but read from f.File
+		if f.Alias != "" && !filepath.IsLocal(filepath.FromSlash(f.Alias)) {
+			return nil, fmt.Errorf("invalid alias %q: %s", f.Alias, f.File)
+		}
+
 		if f.Metafile {
 			i := slices.IndexFunc(metafiles, func(m *MetafileToml) bool {
 				return m.IndexName == f.File
@@ -122,12 +181,24 @@ func tomlToPack(
 
 			modDir := filepath.ToSlash(filepath.Join(filepath.Dir(pack.Index.File), filepath.Dir(f.File)))
 			modPath := filepath.ToSlash(filepath.Join(modDir, metafile.Filename))
+			if f.Alias != "" {
+				modPath = filepath.ToSlash(filepath.Join(filepath.Dir(pack.Index.File), f.Alias))
+			}
 			m := &Mod{
 				Path:       modPath,
+				Name:       metafile.Name,
 				Hash:       metafile.Download.Hash,
 				HashFormat: metafile.Download.HashFormat,
 				Side:       Side(metafile.Side),
 				Downloads:  dl,
+				Preserve:   f.Preserve,
+				fileName:   metafile.Filename,
+			}
+			if metafile.Option != nil && metafile.Option.Optional {
+				m.Option = &ModOption{
+					Default:     metafile.Option.Default,
+					Description: metafile.Option.Description,
+				}
 			}
 
 			mods = append(mods, m)
@@ -137,18 +208,23 @@ func tomlToPack(
 				hashFmt = index.HashFormat
 			}
 			modPath := filepath.ToSlash(filepath.Join(filepath.Dir(pack.Index.File), f.File))
-			modUrl := packUrl.JoinPath("..", modPath)
+			// files of the pack itself, DL_Url is reserved for external downloads
 			dl := &Download{
-				Type: DL_Url,
-				Data: modUrl.String(),
+				Type: DL_Source,
+				Data: modPath,
 			}
 
+			installPath := f.File
+			if f.Alias != "" {
+				installPath = f.Alias
+			}
 			m := &Mod{
-				Path:       f.File,
+				Path:       installPath,
 				Hash:       f.Hash,
 				HashFormat: hashFmt,
 				Side:       Side_Both,
 				Downloads:  dl,
+				Preserve:   f.Preserve,
 			}
 			mods = append(mods, m)
 		}
@@ -158,6 +234,72 @@ func tomlToPack(
 	return ppack, nil
 }
 
+// LoadPack loads the pack at u, which is either a packwiz repository, a
+// Modrinth modpack or a CurseForge modpack export. The pack should be closed
+// once it has been installed.
+func LoadPack(ctx context.Context, u *url.URL, hashFormat, hash string, opts ...RepoOptFn) (*Pack, error) {
+	if IsMrpackUrl(u) {
+		return LoadMrpack(ctx, u, hashFormat, hash, opts...)
+	}
+
+	repo := NewRepository(u, hashFormat, hash, opts...)
+	if getArchiveKind(u.Path) == archiveZip {
+		// zip files are either packwiz repositories or curseforge modpacks
+		f, err := fetchArchive(ctx, u, repo.httpClient, repo.retry, repo.rewrite)
+		if err != nil {
+			return nil, err
+		}
+		a, err := openArchive(f, archiveZip)
+		if err != nil {
+			return nil, err
+		}
+
+		if isCurseModpack(a) {
+			p, err := loadCurseModpack(ctx, f, a, hashFormat, hash, repo)
+			if err != nil {
+				a.Close()
+				return nil, err
+			}
+			return p, nil
+		}
+
+		repo.source, repo.packFile, err = newArchiveSource(a, u)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+	}
+
+	if err := repo.Load(ctx); err != nil {
+		repo.Close()
+		return nil, err
+	}
+	p, err := NewPack(repo)
+	if err != nil {
+		repo.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Close releases resources held by the source of the pack.
+func (p *Pack) Close() error {
+	if c, ok := p.source.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 func NewPack(r *Repository) (*Pack, error) {
-	return tomlToPack(r.Url, r.Pack, r.Index, r.Metafiles)
+	p, err := tomlToPack(r.source, r.Pack, r.Index, r.Metafiles)
+	if err != nil {
+		return nil, err
+	}
+	p.Revision = r.Revision
+	p.packHash = r.packSum
+	p.indexHash = r.indexSum()
+	p.httpClient = r.httpClient
+	p.retry = r.retry
+	p.rewrite = r.rewrite
+	return p, nil
 }
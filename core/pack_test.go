@@ -0,0 +1,72 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_tomlToPackAlias(t *testing.T) {
+	src := &fsSource{fsys: fstest.MapFS{"config/default.toml": {Data: []byte("content")}}}
+	pack := &PackToml{Name: "test"}
+	pack.Index.File = "index.toml"
+	metafiles := []*MetafileToml{{
+		Filename:  "mod.jar",
+		Name:      "Mod",
+		Download:  &MetafileDownload{HashFormat: "sha1", Hash: "aa", Url: "https://example.com/mod.jar"},
+		IndexName: "mods/mod.pw.toml",
+	}}
+	indexWithAlias := func(alias string) *IndexToml {
+		return &IndexToml{HashFormat: "sha256", Files: []IndexedfileToml{
+			{File: "config/default.toml", Hash: sha256Hex([]byte("content")), Alias: alias},
+			{File: "mods/mod.pw.toml", Metafile: true, Alias: "mods/renamed.jar"},
+		}}
+	}
+
+	p, err := tomlToPack(src, pack, indexWithAlias("config/a.toml"), metafiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := findMod(p, "config/a.toml"); m == nil || m.Downloads.Data != "config/default.toml" {
+		t.Errorf("aliased file = %+v, want it read from config/default.toml", m)
+	}
+	if m := findMod(p, "mods/renamed.jar"); m == nil || m.Downloads.Data != "https://example.com/mod.jar" {
+		t.Errorf("aliased metafile = %+v", m)
+	}
+
+	if _, err := tomlToPack(src, pack, indexWithAlias("../a.toml"), metafiles); err == nil {
+		t.Error("tomlToPack() accepted an alias outside the instance")
+	}
+
+	// renaming through an alias removes the old file
+	dir := t.TempDir()
+	for _, alias := range []string{"config/a.toml", "config/b.toml"} {
+		p, err := tomlToPack(src, pack, indexWithAlias(alias), metafiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Mods = p.Mods[:1] // only the pack file, the metafile mod is not served
+		inst, err := NewLocalInstaller(p, dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := inst.Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exists(t, filepath.Join(dir, "config", "a.toml")) {
+		t.Error("old alias was not removed")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config", "b.toml"))
+	if err != nil || string(data) != "content" {
+		t.Errorf("new alias = %q, %v", data, err)
+	}
+}
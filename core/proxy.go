@@ -0,0 +1,184 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// CacheProxy serves the downloads of packs by their hash, so that the
+// installers on a local network share a single download of every file. See
+// LocalInstaller.ProxyCache.
+//
+// Files are only fetched for hashes of the packs added to the proxy, and are
+// verified against them before they are kept in the store. Files already in
+// the store are served without asking the network, also after a restart.
+type CacheProxy struct {
+	store *Store
+	mux   *http.ServeMux
+
+	mu   sync.RWMutex
+	mods map[string]proxyMod // by "<format>/<hash>"
+	// locks makes concurrent requests for a file wait for a single fetch
+	locks sync.Map
+}
+
+type proxyMod struct {
+	mod  *Mod
+	inst *LocalInstaller
+}
+
+// NewCacheProxy returns a proxy keeping files in store.
+func NewCacheProxy(store *Store) *CacheProxy {
+	c := &CacheProxy{store: store, mods: make(map[string]proxyMod)}
+	c.mux = http.NewServeMux()
+	c.mux.HandleFunc("GET /objects/{format}/{hash}", c.serveObject)
+	return c
+}
+
+// AddPack makes the downloads of p available from the proxy, for every game
+// side and option.
+func (c *CacheProxy) AddPack(p *Pack) error {
+	// downloads are staged next to the store, so they can be linked into it
+	inst, err := NewLocalInstaller(p, filepath.Join(c.store.Dir, "proxy"), Side_Both)
+	if err != nil {
+		return err
+	}
+	inst.Store = &Store{Dir: c.store.Dir, Link: LinkHardlink}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range p.Mods {
+		if m.Downloads.Type != DL_Url && m.Downloads.Type != DL_Curseforge {
+			continue
+		}
+		c.mods[m.HashFormat+"/"+strings.ToLower(m.Hash)] = proxyMod{mod: m, inst: inst}
+	}
+
+	// recorded like an installation, so that the store keeps the files
+	mods := make([]*Mod, 0, len(c.mods))
+	for _, pm := range c.mods {
+		mods = append(mods, pm.mod)
+	}
+	if err := inst.saveCache("installed", mods); err != nil {
+		return err
+	}
+	return c.store.register(inst.BaseDir)
+}
+
+func (c *CacheProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+func (c *CacheProxy) serveObject(w http.ResponseWriter, r *http.Request) {
+	format, hash := r.PathValue("format"), strings.ToLower(r.PathValue("hash"))
+	c.mu.RLock()
+	pm, ok := c.mods[format+"/"+hash]
+	c.mu.RUnlock()
+	if !ok {
+		http.Error(w, "unknown file", http.StatusNotFound)
+		return
+	}
+
+	// the fetch goes on for other clients when this one disconnects
+	// errors can hold upstream urls and their tokens, so clients only get
+	// a generic message
+	obj, err := c.fetch(context.WithoutCancel(r.Context()), pm)
+	if err != nil {
+		log.Printf("proxy: fetch %s: %v", pm.mod.Path, err)
+		http.Error(w, "upstream fetch failed", http.StatusBadGateway)
+		return
+	}
+	f, err := os.Open(obj)
+	if err != nil {
+		log.Printf("proxy: open %s: %v", pm.mod.Path, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		log.Printf("proxy: stat %s: %v", pm.mod.Path, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	// the content never changes, so interrupted downloads can be resumed
+	w.Header().Set("ETag", `"`+hash+`"`)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
+
+// fetch returns the path of the file of pm in the store, downloading it
+// first if it is not there yet.
+func (c *CacheProxy) fetch(ctx context.Context, pm proxyMod) (string, error) {
+	m := pm.mod
+	obj, err := c.store.objectPath(m.HashFormat, m.Hash)
+	if err != nil {
+		return "", err
+	}
+	mu, _ := c.locks.LoadOrStore(obj, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(obj); err == nil {
+		return obj, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	// installing a copy of m stages the download and adds it to the store
+	staged := *m
+	staged.Path = m.HashFormat + "-" + strings.ToLower(m.Hash)
+	staged.Preserve = false
+	defer os.Remove(filepath.Join(pm.inst.BaseDir, staged.Path))
+	if err := pm.inst.InstallMod(ctx, &staged); err != nil {
+		return "", fmt.Errorf("fetch %s: %w", m.Path, err)
+	}
+	return obj, nil
+}
+
+// Prefetch downloads every file of the added packs that is not in the store
+// yet, up to jobs at once. Files failing to download are skipped, their
+// errors are returned together.
+func (c *CacheProxy) Prefetch(ctx context.Context, jobs int) error {
+	c.mu.RLock()
+	mods := make([]proxyMod, 0, len(c.mods))
+	for _, pm := range c.mods {
+		mods = append(mods, pm)
+	}
+	c.mu.RUnlock()
+
+	var (
+		errs []error
+		mut  sync.Mutex
+	)
+	eg := &errgroup.Group{}
+	eg.SetLimit(max(jobs, 1))
+	for _, pm := range mods {
+		pm := pm // capture for closure
+		eg.Go(func() error {
+			if _, err := c.fetch(ctx, pm); err != nil {
+				mut.Lock()
+				errs = append(errs, err)
+				mut.Unlock()
+			}
+			return nil
+		})
+	}
+	eg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCacheProxy(t *testing.T) {
+	var reqs atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		w.Write([]byte("jar " + r.URL.Path))
+	}))
+	defer origin.Close()
+
+	newPack := func(names ...string) *Pack {
+		p := &Pack{Name: "test"}
+		for _, name := range names {
+			p.Mods = append(p.Mods, &Mod{
+				Path:       "mods/" + name,
+				Hash:       sha256Hex([]byte("jar /" + name)),
+				HashFormat: "sha256",
+				Side:       Side_Both,
+				Downloads:  &Download{Type: DL_Url, Data: origin.URL + "/" + name},
+			})
+		}
+		return p
+	}
+	install := func(proxyUrl string, p *Pack) {
+		t.Helper()
+		dir := t.TempDir()
+		inst, err := NewLocalInstaller(p, dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inst.ProxyCache = proxyUrl
+		if _, err := inst.Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range p.Mods {
+			data, err := os.ReadFile(filepath.Join(dir, m.Path))
+			if err != nil || sha256Hex(data) != m.Hash {
+				t.Errorf("installed %s = %q, %v", m.Path, data, err)
+			}
+		}
+	}
+
+	storeDir := t.TempDir()
+	proxy := NewCacheProxy(&Store{Dir: storeDir})
+	if err := proxy.AddPack(newPack("a.jar", "b.jar")); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(proxy)
+
+	// every file is downloaded from the origin once, files the proxy does
+	// not know are downloaded directly
+	for range 3 {
+		install(srv.URL, newPack("a.jar", "b.jar", "c.jar"))
+	}
+	if n := reqs.Load(); n != 5 {
+		t.Errorf("origin got %d requests, want 5", n)
+	}
+
+	// the store survives a restart
+	srv.Close()
+	proxy = NewCacheProxy(&Store{Dir: storeDir})
+	if err := proxy.AddPack(newPack("a.jar", "b.jar")); err != nil {
+		t.Fatal(err)
+	}
+	srv = httptest.NewServer(proxy)
+	defer srv.Close()
+	reqs.Store(0)
+	install(srv.URL, newPack("a.jar", "b.jar"))
+	if n := reqs.Load(); n != 0 {
+		t.Errorf("origin got %d requests after a restart, want 0", n)
+	}
+
+	// the files are kept by the store
+	if removed, _, err := (&Store{Dir: storeDir}).GC(); err != nil || removed != 0 {
+		t.Errorf("GC() = %d, %v, want nothing removed", removed, err)
+	}
+
+	// installs go on without the proxy
+	reqs.Store(0)
+	install("http://127.0.0.1:1", newPack("a.jar"))
+	if n := reqs.Load(); n != 1 {
+		t.Errorf("origin got %d requests without a proxy, want 1", n)
+	}
+}
+
+func TestCacheProxy_FetchError(t *testing.T) {
+	origin := httptest.NewServer(http.NotFoundHandler())
+	defer origin.Close()
+
+	hash := sha256Hex([]byte("jar"))
+	proxy := NewCacheProxy(&Store{Dir: t.TempDir()})
+	if err := proxy.AddPack(&Pack{Name: "test", Mods: []*Mod{{
+		Path:       "mods/a.jar",
+		Hash:       hash,
+		HashFormat: "sha256",
+		Side:       Side_Both,
+		Downloads:  &Download{Type: DL_Url, Data: origin.URL + "/a.jar?token=secret"},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	// clients do not see the upstream url
+	res, err := http.Get(srv.URL + "/objects/sha256/" + hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusBadGateway)
+	}
+	if strings.Contains(string(body), origin.URL) || strings.Contains(string(body), "secret") {
+		t.Errorf("body = %q, leaks the upstream url", body)
+	}
+}
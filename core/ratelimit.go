@@ -0,0 +1,88 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// smallest burst of a rate limiter, reads are split into chunks of at most
+// the burst size
+const minRateBurst = 32 << 10
+
+// RateLimiter limits the combined rate at which responses are received by
+// every client using it. It is safe to change the limit while downloads are
+// running.
+type RateLimiter struct {
+	l *rate.Limiter
+}
+
+// NewRateLimiter returns a limiter of bytesPerSecond, no limit if < 1.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	r := &RateLimiter{l: rate.NewLimiter(rate.Inf, minRateBurst)}
+	r.SetLimit(bytesPerSecond)
+	return r
+}
+
+// SetLimit changes the limit to bytesPerSecond, no limit if < 1.
+func (r *RateLimiter) SetLimit(bytesPerSecond int64) {
+	if bytesPerSecond < 1 {
+		r.l.SetLimit(rate.Inf)
+		return
+	}
+	// a burst of about a second keeps the rate smooth without many wakeups
+	r.l.SetBurst(int(max(min(bytesPerSecond, 1<<30), minRateBurst)))
+	r.l.SetLimit(rate.Limit(bytesPerSecond))
+}
+
+// Limit returns the current limit in bytes per second, 0 if there is none.
+func (r *RateLimiter) Limit() int64 {
+	if r.l.Limit() == rate.Inf {
+		return 0
+	}
+	return int64(r.l.Limit())
+}
+
+// rateLimitTransport slows down reading response bodies to the rate of its
+// limiter.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = &rateLimitedBody{ReadCloser: res.Body, ctx: req.Context(), limiter: t.limiter}
+	return res, nil
+}
+
+type rateLimitedBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *RateLimiter
+}
+
+func (b *rateLimitedBody) Read(p []byte) (int, error) {
+	if len(p) > minRateBurst {
+		p = p[:minRateBurst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		// the burst is never below the size of a read
+		if werr := b.limiter.l.WaitN(b.ctx, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,15 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import "golang.org/x/sys/unix"
+
+// reflink creates dst as a copy on write clone of src, which fails on file
+// systems without support for them.
+func reflink(src, dst string) error {
+	return unix.Clonefile(src, dst, 0)
+}
@@ -0,0 +1,16 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !linux && !darwin
+
+package core
+
+import "errors"
+
+// reflink is not supported on this platform, files are copied instead.
+func reflink(src, dst string) error {
+	return errors.ErrUnsupported
+}
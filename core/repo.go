@@ -8,8 +8,13 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"golang.org/x/sync/errgroup"
@@ -17,6 +22,50 @@ import (
 
 type RepoOptFn func(r *Repository)
 
+// WithHttpClient sets the client used for the requests of the repository,
+// and by the installer of the pack loaded from it.
+func WithHttpClient(c *http.Client) RepoOptFn {
+	return func(r *Repository) {
+		r.httpClient = c
+	}
+}
+
+// WithCacheDir caches the pack metadata in dir. Metafiles are kept by their
+// hash in the index, everything else read over http is only downloaded again
+// when the server reports a change.
+func WithCacheDir(dir string) RepoOptFn {
+	return func(r *Repository) {
+		r.cacheDir = dir
+	}
+}
+
+// WithRetryPolicy sets how failed requests of the repository, and the
+// downloads of the installer of its pack, are retried instead of
+// DefaultRetryPolicy.
+func WithRetryPolicy(p *RetryPolicy) RepoOptFn {
+	return func(r *Repository) {
+		r.retry = p
+	}
+}
+
+// WithRewriteRules redirects the requests of the repository, and the
+// downloads of the installer of its pack, to mirrors.
+func WithRewriteRules(rules RewriteRules) RepoOptFn {
+	return func(r *Repository) {
+		r.rewrite = rules
+	}
+}
+
+// WithSkipInstalled stops Load with ErrUpToDate before the index is read,
+// if the same pack.toml and index are installed in dir for side and none of
+// the installed files changed since.
+func WithSkipInstalled(dir string, side Side) RepoOptFn {
+	return func(r *Repository) {
+		r.installedDir = dir
+		r.installedSide = side
+	}
+}
+
 type Repository struct {
 	Url            *url.URL
 	Pack           *PackToml
@@ -24,16 +73,50 @@ type Repository struct {
 	Metafiles      []*MetafileToml
 	PackHashFormat string
 	PackHash       string
+	Revision       string // resolved revision of versioned sources, e.g. a git commit
 	httpClient     *http.Client
+	retry          *RetryPolicy
+	rewrite        RewriteRules
+	cacheDir       string
+	metafileCache  *metafileCache
+	source         Source
+	packFile       string
+	packSum        string // "<format>:<hash>" of the loaded pack.toml
+	installedDir   string
+	installedSide  Side
 }
 
-func NewRepository(url *url.URL, hashFormat, hash string) *Repository {
-	return &Repository{
+func NewRepository(url *url.URL, hashFormat, hash string, opts ...RepoOptFn) *Repository {
+	r := &Repository{
 		Url:            url,
 		PackHashFormat: hashFormat,
 		PackHash:       hash,
 		httpClient:     http.DefaultClient,
+		retry:          DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
+}
+
+func (r *Repository) openSource(ctx context.Context) error {
+	if r.source != nil {
+		return nil
+	}
+	src, packFile, err := newSource(ctx, r.Url, r.httpClient, r.retry, r.rewrite)
+	if err != nil {
+		return err
+	}
+	if hs, ok := src.(*httpSource); ok && r.cacheDir != "" {
+		hs.cache = newHttpCache(filepath.Join(r.cacheDir, "http"))
+	}
+	r.source = src
+	r.packFile = packFile
+	if rs, ok := src.(revisionSource); ok {
+		r.Revision = rs.Revision()
+	}
+	return nil
 }
 
 func (r *Repository) loadPack(ctx context.Context) (*PackToml, error) {
@@ -42,13 +125,17 @@ func (r *Repository) loadPack(ctx context.Context) (*PackToml, error) {
 		err  error
 	)
 
+	if err = r.openSource(ctx); err != nil {
+		return nil, err
+	}
+
 	if r.PackHash == "" {
-		data, err = httpGetBytes(ctx, r.httpClient, r.Url.String())
+		data, err = r.source.ReadFile(ctx, r.packFile)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		data, err = httpGetValidBytes(ctx, r.httpClient, r.Url.String(), r.PackHashFormat, r.PackHash)
+		data, err = readValidFile(ctx, r.source, r.packFile, r.PackHashFormat, r.PackHash)
 		if err != nil {
 			return nil, err
 		}
@@ -59,6 +146,7 @@ func (r *Repository) loadPack(ctx context.Context) (*PackToml, error) {
 		return nil, err
 	}
 	r.Pack = pack
+	r.packSum = "sha256:" + sha256Hex(data)
 	return pack, nil
 }
 
@@ -70,10 +158,10 @@ func (r *Repository) loadIndex(ctx context.Context) (*IndexToml, error) {
 		}
 	}
 
-	data, err := httpGetValidBytes(
+	data, err := readValidFile(
 		ctx,
-		r.httpClient,
-		r.IndexUrl().String(),
+		r.source,
+		r.indexFile(),
 		r.Pack.Index.HashFormat,
 		r.Pack.Index.Hash,
 	)
@@ -97,6 +185,10 @@ func (r *Repository) loadMetafiles(ctx context.Context) ([]*MetafileToml, error)
 		}
 	}
 
+	if r.cacheDir != "" && r.metafileCache == nil {
+		r.metafileCache = newMetafileCache(filepath.Join(r.cacheDir, "metafiles"))
+	}
+
 	var mods = make([]*MetafileToml, 0, len(r.Index.Files))
 	eg := errgroup.Group{}
 	mutex := sync.Mutex{}
@@ -107,12 +199,12 @@ func (r *Repository) loadMetafiles(ctx context.Context) ([]*MetafileToml, error)
 				return nil
 			}
 
-			metafileUrl := r.IndexUrl().JoinPath("..", indexedFile.File).String()
+			metafile := path.Join(path.Dir(r.indexFile()), indexedFile.File)
 			hashFmt := indexedFile.HashFormat
 			if hashFmt == "" {
 				hashFmt = r.Index.HashFormat
 			}
-			data, err := httpGetValidBytes(ctx, r.httpClient, metafileUrl, hashFmt, indexedFile.Hash)
+			data, err := r.readMetafile(ctx, metafile, hashFmt, indexedFile.Hash)
 			if err != nil {
 				return err
 			}
@@ -139,10 +231,68 @@ func (r *Repository) loadMetafiles(ctx context.Context) ([]*MetafileToml, error)
 }
 
 func (r *Repository) Load(ctx context.Context) error {
+	if r.installedDir != "" {
+		// pack.toml holds the hash of the index, which is only fetched
+		// when the install is out of date
+		if _, err := r.loadPack(ctx); err != nil {
+			return err
+		}
+		inst, err := NewLocalInstaller(&Pack{}, r.installedDir, r.installedSide)
+		if err != nil {
+			return err
+		}
+		ok, err := inst.isUpToDate(r.packSum, r.indexSum())
+		if err != nil {
+			return fmt.Errorf("check install state: %w", err)
+		}
+		if ok {
+			return ErrUpToDate
+		}
+	}
+
 	_, err := r.loadMetafiles(ctx)
 	if err != nil {
 		return err
 	}
+
+	// drop cached files the pack no longer has
+	if hs, ok := r.source.(*httpSource); ok && hs.cache != nil {
+		if err := hs.cache.prune(); err != nil {
+			return fmt.Errorf("prune cache: %w", err)
+		}
+	}
+	if r.metafileCache != nil {
+		if err := r.metafileCache.prune(); err != nil {
+			return fmt.Errorf("prune cache: %w", err)
+		}
+	}
+	return nil
+}
+
+// readMetafile reads a metafile from the cache if one with the same hash was
+// read before, or from the source otherwise.
+func (r *Repository) readMetafile(ctx context.Context, name, hashFormat, hash string) ([]byte, error) {
+	if r.metafileCache != nil {
+		if data, ok := r.metafileCache.get(hashFormat, hash); ok {
+			return data, nil
+		}
+	}
+	data, err := readValidFile(ctx, r.source, name, hashFormat, hash)
+	if err != nil {
+		return nil, err
+	}
+	if r.metafileCache != nil {
+		r.metafileCache.put(hashFormat, hash, data)
+	}
+	return data, nil
+}
+
+// Close releases resources held by the pack source, such as the temporary
+// checkout of a git repository.
+func (r *Repository) Close() error {
+	if c, ok := r.source.(io.Closer); ok {
+		return c.Close()
+	}
 	return nil
 }
 
@@ -153,3 +303,16 @@ func (r *Repository) BaseUrl() *url.URL {
 func (r *Repository) IndexUrl() *url.URL {
 	return r.BaseUrl().JoinPath(r.Pack.Index.File)
 }
+
+// indexSum returns the hash of the index as "<format>:<hash>".
+func (r *Repository) indexSum() string {
+	if r.Pack == nil {
+		return ""
+	}
+	return r.Pack.Index.HashFormat + ":" + strings.ToLower(r.Pack.Index.Hash)
+}
+
+// indexFile returns the path of index.toml relative to the pack root.
+func (r *Repository) indexFile() string {
+	return path.Clean(r.Pack.Index.File)
+}
@@ -0,0 +1,510 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// writeTestPack writes a minimal packwiz repository into dir and returns the
+// path of its pack.toml.
+func writeTestPack(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+
+	index := "hash-format = \"sha256\"\n"
+	for name, content := range files {
+		index += fmt.Sprintf("\n[[files]]\nfile = %q\nhash = %q\n", name, sha256Hex([]byte(content)))
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+	writeTestFile(t, filepath.Join(dir, "index.toml"), index)
+
+	pack := fmt.Sprintf(`name = "test"
+pack-format = "packwiz:1.1.0"
+
+[index]
+file = "index.toml"
+hash-format = "sha256"
+hash = %q
+`, sha256Hex([]byte(index)))
+	p := filepath.Join(dir, "pack.toml")
+	writeTestFile(t, p, pack)
+	return p
+}
+
+func writeTestFile(t *testing.T, p string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepository_LoadLocal(t *testing.T) {
+	src := t.TempDir()
+	packPath := writeTestPack(t, src, map[string]string{
+		"config/a.txt": "hello",
+		"b.txt":        "world",
+	})
+
+	u, err := ParsePackUrl(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "file" {
+		t.Fatalf("ParsePackUrl() scheme = %q, want file", u.Scheme)
+	}
+
+	repo := NewRepository(u, "", "")
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	pack, err := NewPack(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range pack.Mods {
+		if m.Downloads.Type != DL_Source {
+			t.Errorf("mod %s download type = %q, want %q", m.Path, m.Downloads.Type, DL_Source)
+		}
+	}
+
+	dst := t.TempDir()
+	inst, err := NewLocalInstaller(pack, dst, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates, err := inst.Install(context.Background())
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if len(updates.Added) != 2 {
+		t.Errorf("Install() added %d files, want 2", len(updates.Added))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "config", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("installed content = %q, want %q", data, "hello")
+	}
+}
+
+func TestRepository_LoadLocalHashMismatch(t *testing.T) {
+	src := t.TempDir()
+	writeTestPack(t, src, map[string]string{"a.txt": "hello"})
+	writeTestFile(t, filepath.Join(src, "index.toml"), "tampered")
+
+	u, err := ParsePackUrl(filepath.Join(src, "pack.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepository(u, "", "")
+	if err := repo.Load(context.Background()); err == nil {
+		t.Fatal("Load() succeeded with a tampered index")
+	}
+}
+
+func TestRepository_LoadSkipInstalled(t *testing.T) {
+	src := t.TempDir()
+	packPath := writeTestPack(t, src, map[string]string{
+		"a.txt": "hello",
+		"b.txt": "world",
+	})
+	u, err := ParsePackUrl(packPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	install := func(side Side) error {
+		t.Helper()
+		pack, err := LoadPack(context.Background(), u, "", "", WithSkipInstalled(dst, side))
+		if err != nil {
+			return err
+		}
+		inst, err := NewLocalInstaller(pack, dst, side)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := inst.Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}
+
+	if err := install(Side_Both); err != nil {
+		t.Fatalf("first install error = %v", err)
+	}
+	if err := install(Side_Both); !errors.Is(err, ErrUpToDate) {
+		t.Fatalf("unchanged install error = %v, want ErrUpToDate", err)
+	}
+
+	// the index is not read while pack.toml is unchanged
+	index := must(os.ReadFile(filepath.Join(src, "index.toml")))
+	writeTestFile(t, filepath.Join(src, "index.toml"), "corrupt")
+	if err := install(Side_Both); !errors.Is(err, ErrUpToDate) {
+		t.Fatalf("install with an unread index error = %v, want ErrUpToDate", err)
+	}
+	writeTestFile(t, filepath.Join(src, "index.toml"), string(index))
+
+	if err := install(Side_Server); err != nil {
+		t.Fatalf("install for another side error = %v", err)
+	}
+	if err := install(Side_Server); !errors.Is(err, ErrUpToDate) {
+		t.Fatalf("unchanged install error = %v, want ErrUpToDate", err)
+	}
+
+	// changed and removed files need a full run
+	writeTestFile(t, filepath.Join(dst, "a.txt"), "changed")
+	if err := install(Side_Server); err != nil {
+		t.Fatalf("install with a changed file error = %v", err)
+	}
+	if data := must(os.ReadFile(filepath.Join(dst, "a.txt"))); string(data) != "hello" {
+		t.Errorf("repaired content = %q, want %q", data, "hello")
+	}
+	if err := os.Remove(filepath.Join(dst, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := install(Side_Server); err != nil {
+		t.Fatalf("install with a removed file error = %v", err)
+	}
+
+	// so does a changed pack
+	writeTestPack(t, src, map[string]string{"a.txt": "hello"})
+	if err := install(Side_Server); err != nil {
+		t.Fatalf("install of a changed pack error = %v", err)
+	}
+	if exists(t, filepath.Join(dst, "b.txt")) {
+		t.Error("file removed from the pack is still installed")
+	}
+}
+
+func TestRepository_LoadGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "--quiet")
+	writeTestPack(t, filepath.Join(dir, "modpack"), map[string]string{"a.txt": "v1"})
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1")
+	want := git("rev-parse", "HEAD")
+
+	writeTestPack(t, filepath.Join(dir, "modpack"), map[string]string{"a.txt": "v2"})
+	git("commit", "--quiet", "-am", "v2")
+
+	u, err := url.Parse("git+file://" + filepath.ToSlash(dir) + "#v1:modpack/pack.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepository(u, "", "")
+	defer repo.Close()
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if repo.Revision != want {
+		t.Errorf("Revision = %q, want %q", repo.Revision, want)
+	}
+
+	pack, err := NewPack(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewLocalInstaller(pack, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(inst.BaseDir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "v1" {
+		t.Errorf("installed content = %q, want %q", data, "v1")
+	}
+	state, err := inst.GetInstallState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Revision != want {
+		t.Errorf("state revision = %q, want %q", state.Revision, want)
+	}
+
+	// failed reads leave the others working
+	for _, name := range []string{"missing.txt", "."} {
+		if _, err := repo.source.ReadFile(context.Background(), name); err == nil {
+			t.Errorf("ReadFile(%q) succeeded", name)
+		}
+		data, err := repo.source.ReadFile(context.Background(), "a.txt")
+		if err != nil || string(data) != "v1" {
+			t.Errorf("ReadFile() after %q = %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestRepository_LoadGitHttp(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	work := filepath.Join(dir, "work")
+	bare := filepath.Join(dir, "pack.git")
+	git("init", "--quiet", work)
+	writeTestPack(t, work, map[string]string{"a.txt": "over http"})
+	git("-C", work, "add", "-A")
+	git("-C", work, "commit", "--quiet", "-m", "v1")
+	git("-C", work, "tag", "v1")
+	git("clone", "--quiet", "--bare", work, bare)
+	git("-C", bare, "update-server-info")
+
+	// served with the dumb http protocol, which needs no git on the server
+	var userAgents atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		userAgents.Store(r.UserAgent())
+		http.StripPrefix("/pack.git", http.FileServer(http.Dir(bare))).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c, err := (&HttpConfig{
+		UserAgent:   "test/1.0",
+		Credentials: []Credentials{{Host: strings.TrimPrefix(srv.URL, "http://"), Token: "secret"}},
+	}).NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("git+" + srv.URL + "/pack.git#v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepository(u, "", "", WithHttpClient(c))
+	defer repo.Close()
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if ua, _ := userAgents.Load().(string); !strings.HasSuffix(ua, " test/1.0") {
+		t.Errorf("User-Agent = %q, want the configured one", ua)
+	}
+	data, err := repo.source.ReadFile(context.Background(), "a.txt")
+	if err != nil || string(data) != "over http" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+}
+
+func TestRepository_LoadArchive(t *testing.T) {
+	src := t.TempDir()
+	writeTestPack(t, src, map[string]string{"config/a.txt": "zipped"})
+
+	// archives built by CI usually wrap everything in a top level directory
+	archive := filepath.Join(t.TempDir(), "pack.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"pack.toml", "index.toml", "config/a.txt"} {
+		data, err := os.ReadFile(filepath.Join(src, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create("pack-main/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	u, err := ParsePackUrl(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRepository(u, "", "")
+	if err := repo.Load(context.Background()); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	pack, err := NewPack(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewLocalInstaller(pack, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(inst.BaseDir, "config", "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "zipped" {
+		t.Errorf("installed content = %q, want %q", data, "zipped")
+	}
+}
+
+func TestRepository_LoadHttpCache(t *testing.T) {
+	src := t.TempDir()
+	writeTestPack(t, src, map[string]string{"a.txt": "hello"})
+	writeTestFile(t, filepath.Join(src, "mods", "a.pw.toml"), `name = "A"
+filename = "a.jar"
+
+[download]
+url = "https://example.com/a.jar"
+hash-format = "sha256"
+hash = "00"
+`)
+	// reference the metafile from the index
+	index := "hash-format = \"sha256\"\n\n[[files]]\nfile = \"mods/a.pw.toml\"\nmetafile = true\n" +
+		fmt.Sprintf("hash = %q\n", sha256Hex(must(os.ReadFile(filepath.Join(src, "mods", "a.pw.toml")))))
+	writeTestFile(t, filepath.Join(src, "index.toml"), index)
+	packToml := fmt.Sprintf("name = \"test\"\npack-format = \"packwiz:1.1.0\"\n\n[index]\nfile = \"index.toml\"\nhash-format = \"sha256\"\nhash = %q\n", sha256Hex([]byte(index)))
+	writeTestFile(t, filepath.Join(src, "pack.toml"), packToml)
+
+	statuses := map[int]int{}
+	fileServer := http.FileServer(http.Dir(src))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		fileServer.ServeHTTP(rec, r)
+		statuses[rec.status]++
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/pack.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cacheDir := t.TempDir()
+	load := func() {
+		t.Helper()
+		repo := NewRepository(u, "", "", WithCacheDir(cacheDir))
+		if err := repo.Load(context.Background()); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if len(repo.Metafiles) != 1 || repo.Metafiles[0].Name != "A" {
+			t.Fatalf("Metafiles = %v", repo.Metafiles)
+		}
+	}
+
+	load()
+	if statuses[http.StatusOK] != 3 {
+		t.Fatalf("first load got %v responses, want 3 200s", statuses)
+	}
+	clear(statuses)
+	load()
+	// the unchanged metafile is not requested at all
+	if statuses[http.StatusNotModified] != 2 || statuses[http.StatusOK] != 0 {
+		t.Fatalf("second load got %v responses, want 2 304s", statuses)
+	}
+
+	// stale cache entries are still verified, and fetched again
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "http", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		var meta httpCacheMeta
+		if err := json.Unmarshal(must(os.ReadFile(e)), &meta); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(meta.Url, "/pack.toml") {
+			continue
+		}
+		meta.Sha256 = sha256Hex([]byte("stale"))
+		writeTestFile(t, strings.TrimSuffix(e, ".json"), "stale")
+		writeTestFile(t, e, string(must(json.Marshal(meta))))
+	}
+	clear(statuses)
+	load()
+	if statuses[http.StatusOK] != 1 {
+		t.Errorf("stale load got %v responses, want 1 200", statuses)
+	}
+
+	// damaged metafiles are fetched again
+	metafiles, err := filepath.Glob(filepath.Join(cacheDir, "metafiles", "*"))
+	if err != nil || len(metafiles) != 1 {
+		t.Fatalf("cached metafiles = %v, %v", metafiles, err)
+	}
+	writeTestFile(t, metafiles[0], "damaged")
+	clear(statuses)
+	load()
+	if statuses[http.StatusOK] != 1 {
+		t.Errorf("damaged load got %v responses, want 1 200", statuses)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RewriteRule redirects urls starting with Prefix to the first of Mirrors,
+// the others are tried in order when it fails or serves a file not matching
+// its hash. The original url is only used if it is one of the mirrors.
+type RewriteRule struct {
+	Prefix  string
+	Mirrors []string // replacements of Prefix, in order of preference
+}
+
+// ParseRewriteRule parses a rule of the form "<prefix> -> <mirror>[, <mirror>...]".
+func ParseRewriteRule(s string) (RewriteRule, error) {
+	prefix, mirrors, ok := strings.Cut(s, "->")
+	prefix = strings.TrimSpace(prefix)
+	if !ok || prefix == "" {
+		return RewriteRule{}, fmt.Errorf("invalid rewrite rule %q, expected \"<prefix> -> <mirror>[, <mirror>...]\"", s)
+	}
+	rule := RewriteRule{Prefix: prefix}
+	for m := range strings.SplitSeq(mirrors, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			rule.Mirrors = append(rule.Mirrors, m)
+		}
+	}
+	if len(rule.Mirrors) == 0 {
+		return RewriteRule{}, fmt.Errorf("rewrite rule without a mirror: %q", s)
+	}
+	return rule, nil
+}
+
+// RewriteRules are applied to the urls of the pack, its metadata and its
+// downloads. Of the rules matching a url, the one with the longest prefix is
+// used.
+type RewriteRules []RewriteRule
+
+// urls returns the urls to try for u in order, only u itself if no rule
+// matches.
+func (rs RewriteRules) urls(u string) []string {
+	var match *RewriteRule
+	for i := range rs {
+		if strings.HasPrefix(u, rs[i].Prefix) && (match == nil || len(rs[i].Prefix) > len(match.Prefix)) {
+			match = &rs[i]
+		}
+	}
+	if match == nil {
+		return []string{u}
+	}
+	urls := make([]string, 0, len(match.Mirrors))
+	for _, m := range match.Mirrors {
+		urls = append(urls, m+strings.TrimPrefix(u, match.Prefix))
+	}
+	return urls
+}
+
+// tryMirrors calls fn with each of urls in order until one succeeds, and
+// returns the errors of all of them otherwise.
+func tryMirrors(ctx context.Context, urls []string, fn func(u string) error) error {
+	var errs []error
+	for _, u := range urls {
+		err := fn(u)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
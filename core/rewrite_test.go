@@ -0,0 +1,186 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRewriteRules(t *testing.T) {
+	var rules RewriteRules
+	for _, s := range []string{
+		"https://cdn.modrinth.com/ -> https://mirror.internal/modrinth/",
+		"https://cdn.modrinth.com/data/abc/ -> https://a.internal/, https://cdn.modrinth.com/data/abc/",
+	} {
+		rule, err := ParseRewriteRule(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	for _, s := range []string{"https://cdn.modrinth.com/", "-> https://mirror/", "a -> , "} {
+		if _, err := ParseRewriteRule(s); err == nil {
+			t.Errorf("ParseRewriteRule(%q) accepted an invalid rule", s)
+		}
+	}
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://example.com/a.jar", []string{"https://example.com/a.jar"}},
+		{"https://cdn.modrinth.com/data/xyz/a.jar", []string{"https://mirror.internal/modrinth/data/xyz/a.jar"}},
+		// the longest prefix wins
+		{"https://cdn.modrinth.com/data/abc/a.jar", []string{"https://a.internal/a.jar", "https://cdn.modrinth.com/data/abc/a.jar"}},
+	}
+	for _, tt := range tests {
+		if got := rules.urls(tt.url); !slices.Equal(got, tt.want) {
+			t.Errorf("urls(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestLocalInstaller_Mirrors(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/corrupt.jar" {
+			w.Write([]byte("corrupt"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer broken.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("jar"))
+	}))
+	defer mirror.Close()
+
+	dir := t.TempDir()
+	inst, err := NewLocalInstaller(&Pack{}, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst.Retry = nil
+	inst.Rewrite = RewriteRules{{
+		Prefix:  "https://cdn.example.com/",
+		Mirrors: []string{broken.URL + "/", mirror.URL + "/"},
+	}}
+
+	// both a failing mirror and one serving a wrong file are skipped
+	for _, name := range []string{"missing.jar", "corrupt.jar"} {
+		m := &Mod{
+			Path:       "mods/" + name,
+			Hash:       sha256Hex([]byte("jar")),
+			HashFormat: "sha256",
+			Downloads:  &Download{Type: DL_Url, Data: "https://cdn.example.com/" + name},
+		}
+		if err := inst.InstallMod(context.Background(), m); err != nil {
+			t.Fatalf("InstallMod(%s) error = %v", name, err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "mods", name))
+		if err != nil || string(data) != "jar" {
+			t.Errorf("installed content = %q, %v", data, err)
+		}
+	}
+
+	// no mirror has the file
+	inst.Rewrite[0].Mirrors = inst.Rewrite[0].Mirrors[:1]
+	m := &Mod{
+		Path:       "mods/other.jar",
+		Hash:       sha256Hex([]byte("jar")),
+		HashFormat: "sha256",
+		Downloads:  &Download{Type: DL_Url, Data: "https://cdn.example.com/corrupt.jar"},
+	}
+	if err := inst.InstallMod(context.Background(), m); err == nil {
+		t.Error("InstallMod() accepted a file not matching its hash")
+	}
+}
+
+func TestRepository_LoadMirrors(t *testing.T) {
+	src := t.TempDir()
+	writeTestPack(t, src, map[string]string{"a.txt": "hello"})
+	stale := t.TempDir()
+	writeTestPack(t, stale, map[string]string{"a.txt": "old"})
+	// a mirror with an outdated index, the pack.toml refers to the current one
+	writeTestFile(t, filepath.Join(stale, "pack.toml"), string(must(os.ReadFile(filepath.Join(src, "pack.toml")))))
+
+	staleSrv := httptest.NewServer(http.FileServer(http.Dir(stale)))
+	defer staleSrv.Close()
+	srv := httptest.NewServer(http.FileServer(http.Dir(src)))
+	defer srv.Close()
+
+	u := must(url.Parse("https://packs.invalid/test/pack.toml"))
+	pack, err := LoadPack(context.Background(), u, "", "", WithRetryPolicy(nil), WithRewriteRules(RewriteRules{{
+		Prefix:  "https://packs.invalid/test/",
+		Mirrors: []string{staleSrv.URL + "/", srv.URL + "/"},
+	}}))
+	if err != nil {
+		t.Fatalf("LoadPack() error = %v", err)
+	}
+	defer pack.Close()
+
+	dir := t.TempDir()
+	inst, err := NewLocalInstaller(pack, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if data := must(os.ReadFile(filepath.Join(dir, "a.txt"))); string(data) != "hello" {
+		t.Errorf("installed content = %q, want %q", data, "hello")
+	}
+}
+
+func TestRepository_LoadGitMirrors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	mirror := t.TempDir()
+	repo := filepath.Join(mirror, "pack")
+	writeTestPack(t, repo, map[string]string{"a.txt": "hello"})
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "pack"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", args[0], err, out)
+		}
+	}
+
+	// the first mirror does not have the repository
+	u := must(url.Parse("git+https://git.invalid/pack"))
+	pack, err := LoadPack(context.Background(), u, "", "", WithRetryPolicy(nil), WithRewriteRules(RewriteRules{{
+		Prefix:  "https://git.invalid/",
+		Mirrors: []string{"file://" + filepath.ToSlash(t.TempDir()) + "/", "file://" + filepath.ToSlash(mirror) + "/"},
+	}}))
+	if err != nil {
+		t.Fatalf("LoadPack() error = %v", err)
+	}
+	defer pack.Close()
+
+	dir := t.TempDir()
+	inst, err := NewLocalInstaller(pack, dir, Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inst.Install(context.Background()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if data := must(os.ReadFile(filepath.Join(dir, "a.txt"))); string(data) != "hello" {
+		t.Errorf("installed content = %q, want %q", data, "hello")
+	}
+}
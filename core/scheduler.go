@@ -0,0 +1,96 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// default limits of concurrent downloads, independent of the CPU count as
+// downloads are bound by the network
+const (
+	DefaultJobs     = 8
+	DefaultHostJobs = 4
+)
+
+type downloadJob struct {
+	host string // empty for jobs no host limit applies to
+	size int64  // 0 if unknown
+	run  func(ctx context.Context) error
+}
+
+// downloadScheduler runs jobs with a global concurrency limit and a limit
+// per host. The largest pending job whose host has a free slot is started
+// first, so big files don't end up as a long tail.
+type downloadScheduler struct {
+	jobs     int // at least 1
+	hostJobs int // no limit per host if < 1
+}
+
+// run runs all jobs and returns the first error, which cancels the context
+// of the jobs still running and skips the pending ones.
+func (s *downloadScheduler) run(ctx context.Context, jobs []downloadJob) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pending := slices.Clone(jobs)
+	slices.SortStableFunc(pending, func(a, b downloadJob) int {
+		return cmp.Compare(b.size, a.size)
+	})
+
+	var (
+		mu       sync.Mutex
+		cond     = sync.NewCond(&mu)
+		wg       sync.WaitGroup
+		running  int
+		perHost  = make(map[string]int)
+		firstErr error
+	)
+	hostFree := func(j downloadJob) bool {
+		return j.host == "" || s.hostJobs < 1 || perHost[j.host] < s.hostJobs
+	}
+
+	mu.Lock()
+	for len(pending) > 0 && firstErr == nil {
+		if err := ctx.Err(); err != nil {
+			firstErr = err
+			break
+		}
+		i := slices.IndexFunc(pending, hostFree)
+		if running >= max(s.jobs, 1) || i == -1 {
+			cond.Wait()
+			continue
+		}
+
+		j := pending[i]
+		pending = slices.Delete(pending, i, i+1)
+		running++
+		perHost[j.host]++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := j.run(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			running--
+			perHost[j.host]--
+			if err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			cond.Signal()
+		}()
+	}
+	mu.Unlock()
+
+	wg.Wait()
+	return firstErr
+}
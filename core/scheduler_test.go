@@ -0,0 +1,95 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_downloadScheduler(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		running    int
+		perHost    = map[string]int{}
+		maxRunning int
+		maxPerHost = map[string]int{}
+	)
+	job := func(host string) downloadJob {
+		return downloadJob{host: host, run: func(ctx context.Context) error {
+			mu.Lock()
+			running++
+			perHost[host]++
+			maxRunning = max(maxRunning, running)
+			maxPerHost[host] = max(maxPerHost[host], perHost[host])
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			perHost[host]--
+			mu.Unlock()
+			return nil
+		}}
+	}
+	var jobs []downloadJob
+	for range 10 {
+		jobs = append(jobs, job("a"), job("b"), job(""))
+	}
+
+	s := &downloadScheduler{jobs: 5, hostJobs: 2}
+	if err := s.run(context.Background(), jobs); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > 5 {
+		t.Errorf("ran %d jobs at once, want at most 5", maxRunning)
+	}
+	if maxPerHost["a"] > 2 || maxPerHost["b"] > 2 {
+		t.Errorf("ran %v jobs per host at once, want at most 2", maxPerHost)
+	}
+}
+
+func Test_downloadSchedulerOrder(t *testing.T) {
+	var order []int64
+	var jobs []downloadJob
+	for _, size := range []int64{1, 30, 0, 20} {
+		jobs = append(jobs, downloadJob{size: size, run: func(ctx context.Context) error {
+			order = append(order, size)
+			return nil
+		}})
+	}
+
+	s := &downloadScheduler{jobs: 1}
+	if err := s.run(context.Background(), jobs); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{30, 20, 1, 0}; !slices.Equal(order, want) {
+		t.Errorf("ran in order %v, want %v", order, want)
+	}
+}
+
+func Test_downloadSchedulerError(t *testing.T) {
+	errTest := errors.New("test")
+	var ran int
+	jobs := []downloadJob{{run: func(ctx context.Context) error { ran++; return errTest }}}
+	for range 5 {
+		jobs = append(jobs, downloadJob{run: func(ctx context.Context) error { ran++; return nil }})
+	}
+
+	s := &downloadScheduler{jobs: 1}
+	if err := s.run(context.Background(), jobs); !errors.Is(err, errTest) {
+		t.Fatalf("run() error = %v, want %v", err, errTest)
+	}
+	if ran != 1 {
+		t.Errorf("ran %d jobs after an error, want 1", ran)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Source provides read access to the files of a packwiz repository.
+// Names are slash separated and relative to the directory containing pack.toml.
+type Source interface {
+	ReadFile(ctx context.Context, name string) ([]byte, error)
+}
+
+// streamSource is implemented by sources that can copy a file to w without
+// reading all of it into memory.
+type streamSource interface {
+	CopyFile(ctx context.Context, name string, w io.Writer) error
+}
+
+// verifyingSource is implemented by sources that verify files themselves,
+// e.g. to read them again from another location when one fails verification.
+type verifyingSource interface {
+	readValidFile(ctx context.Context, name string, hashFormat, hash string) ([]byte, error)
+}
+
+// revisionSource is implemented by sources that pin the pack to a specific
+// revision, like a git commit.
+type revisionSource interface {
+	Revision() string
+}
+
+type httpSource struct {
+	base       *url.URL
+	httpClient *http.Client
+	retry      *RetryPolicy
+	rewrite    RewriteRules
+	cache      *httpCache // nil if files are not cached
+}
+
+func (s *httpSource) ReadFile(ctx context.Context, name string) ([]byte, error) {
+	var data []byte
+	err := tryMirrors(ctx, s.urls(name), func(u string) error {
+		var err error
+		data, err = s.get(ctx, u)
+		return err
+	})
+	return data, err
+}
+
+func (s *httpSource) get(ctx context.Context, u string) ([]byte, error) {
+	if s.cache != nil {
+		return s.cache.get(ctx, s.httpClient, s.retry, u)
+	}
+	return httpGetBytes(ctx, s.httpClient, s.retry, u)
+}
+
+// readValidFile reads name from the first mirror serving it with the given
+// hash. A cached file failing verification may be stale, it is evicted and
+// read once more.
+func (s *httpSource) readValidFile(ctx context.Context, name string, hashFormat, hash string) ([]byte, error) {
+	var data []byte
+	err := tryMirrors(ctx, s.urls(name), func(u string) error {
+		for attempt := 0; ; attempt++ {
+			d, err := s.get(ctx, u)
+			if err != nil {
+				return err
+			}
+			valid, err := MatchHash(d, hashFormat, hash)
+			if err != nil {
+				return err
+			}
+			if valid {
+				data = d
+				return nil
+			}
+			if s.cache == nil || attempt > 0 {
+				return fmt.Errorf("file hash mismatched: %s", RedactUrl(u))
+			}
+			s.cache.evict(u)
+		}
+	})
+	return data, err
+}
+
+// urls returns the urls name is read from, in order.
+func (s *httpSource) urls(name string) []string {
+	return s.rewrite.urls(s.FileUrl(name).String())
+}
+
+func (s *httpSource) FileUrl(name string) *url.URL {
+	return s.base.JoinPath(name)
+}
+
+type fsSource struct {
+	fsys fs.FS
+}
+
+func (s *fsSource) ReadFile(_ context.Context, name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, name)
+}
+
+func (s *fsSource) CopyFile(_ context.Context, name string, w io.Writer) error {
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// newSource returns the Source serving the directory of the given pack URL,
+// along with the name of pack.toml within it.
+func newSource(ctx context.Context, packUrl *url.URL, c *http.Client, retry *RetryPolicy, rewrite RewriteRules) (Source, string, error) {
+	switch {
+	case strings.HasPrefix(packUrl.Scheme, "git+"):
+		src, packFile, err := openGitSource(ctx, packUrl, c, rewrite)
+		if err != nil {
+			return nil, "", err
+		}
+		return src, packFile, nil
+	case getArchiveKind(packUrl.Path) != "":
+		src, packFile, err := openArchiveSource(ctx, packUrl, c, retry, rewrite)
+		if err != nil {
+			return nil, "", err
+		}
+		return src, packFile, nil
+	case packUrl.Scheme == "http" || packUrl.Scheme == "https":
+		return &httpSource{
+			base:       packUrl.JoinPath(".."),
+			httpClient: c,
+			retry:      retry,
+			rewrite:    rewrite,
+		}, path.Base(packUrl.Path), nil
+	case packUrl.Scheme == "file":
+		p := fileUrlPath(packUrl)
+		return &fsSource{fsys: os.DirFS(filepath.Dir(p))}, filepath.Base(p), nil
+	default:
+		return nil, "", fmt.Errorf("unsupported pack url scheme: %q", packUrl.Scheme)
+	}
+}
+
+func readValidFile(ctx context.Context, src Source, name string, hashFormat string, hash string) ([]byte, error) {
+	if vs, ok := src.(verifyingSource); ok {
+		return vs.readValidFile(ctx, name, hashFormat, hash)
+	}
+	data, err := src.ReadFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	valid, err := MatchHash(data, hashFormat, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, fmt.Errorf("file hash mismatched: %s", name)
+	}
+	return data, nil
+}
+
+// copyValidFile writes the file name of src to p, see writeValidFile.
+func copyValidFile(ctx context.Context, src Source, name string, p string, hashFormat string, hash string) error {
+	err := writeValidFile(p, hashFormat, hash, func(w io.Writer) error {
+		if s, ok := src.(streamSource); ok {
+			return s.CopyFile(ctx, name, w)
+		}
+		data, err := src.ReadFile(ctx, name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if errors.Is(err, errHashMismatch) {
+		return fmt.Errorf("file %w: %s", err, name)
+	}
+	return err
+}
+
+// ParsePackUrl parses the location of a pack.toml. Besides http(s) and file
+// URLs it accepts plain filesystem paths, which are converted to file URLs.
+func ParsePackUrl(s string) (*url.URL, error) {
+	// single letter schemes are windows drive letters, not URLs
+	if u, err := url.Parse(s); err == nil && len(u.Scheme) > 1 {
+		return u, nil
+	}
+
+	abs, err := filepath.Abs(s)
+	if err != nil {
+		return nil, err
+	}
+	p := filepath.ToSlash(abs)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return &url.URL{Scheme: "file", Path: p}, nil
+}
+
+func fileUrlPath(u *url.URL) string {
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.FromSlash(path.Clean(p))
+}
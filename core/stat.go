@@ -0,0 +1,104 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrUpToDate is returned when loading a pack that is already installed, see
+// WithSkipInstalled.
+var ErrUpToDate = errors.New("pack is up to date")
+
+// fileStat records an installed file along with the hash it was verified
+// against. A file with the same size, modification time and inode is assumed
+// not to have changed since, so it is not hashed again.
+type fileStat struct {
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"modTime"`
+	Inode      uint64    `json:"inode,omitempty"` // 0 where unsupported
+	HashFormat string    `json:"hashFormat"`
+	Hash       string    `json:"hash"`
+}
+
+func newFileStat(fi fs.FileInfo, m *Mod) fileStat {
+	return fileStat{
+		Size:       fi.Size(),
+		ModTime:    fi.ModTime(),
+		Inode:      fileInode(fi),
+		HashFormat: m.HashFormat,
+		Hash:       m.Hash,
+	}
+}
+
+// matches reports whether fi is the file that was verified to have the hash
+// of m.
+func (s fileStat) matches(fi fs.FileInfo, m *Mod) bool {
+	return fi.Mode().IsRegular() &&
+		s.Size == fi.Size() &&
+		s.ModTime.Equal(fi.ModTime()) &&
+		s.Inode == fileInode(fi) &&
+		s.HashFormat == m.HashFormat &&
+		strings.EqualFold(s.Hash, m.Hash)
+}
+
+func (i *LocalInstaller) setFileStats(stats map[string]fileStat) error {
+	return i.saveCache("stat", stats)
+}
+
+func (i *LocalInstaller) getFileStats() (map[string]fileStat, error) {
+	var stats map[string]fileStat
+	err := i.restoreCache("stat", &stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// isUpToDate reports whether the pack.toml and index with the given hashes
+// were installed for the game side of i by the last installation, and none
+// of the installed files was changed or removed since. Files are compared by
+// their stat only, which is cheap enough to run on every start.
+func (i *LocalInstaller) isUpToDate(packHash, indexHash string) (bool, error) {
+	state, err := i.GetInstallState()
+	if err != nil {
+		return false, err
+	}
+	if state.PackHash == "" || state.PackHash != packHash || state.IndexHash != indexHash || state.Side != i.GameSide {
+		return false, nil
+	}
+
+	mods, err := i.getInstalledMods()
+	if err != nil {
+		return false, err
+	}
+	stats, err := i.getFileStats()
+	if err != nil {
+		return false, err
+	}
+	for _, m := range mods {
+		fi, err := os.Stat(filepath.Join(i.BaseDir, m.Path))
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if m.Preserve {
+			continue
+		}
+		if s, ok := stats[m.Path]; !ok || !s.matches(fi, m) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !unix
+
+package core
+
+import "io/fs"
+
+// fileInode returns 0, the file index on windows is only available from an
+// open handle.
+func fileInode(fi fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,23 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build unix
+
+package core
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileInode returns the inode number of a file, replacing a file changes it
+// even if the size and modification time are kept.
+func fileInode(fi fs.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,320 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// LinkMode selects how files from the store are placed into an installation.
+type LinkMode string
+
+const (
+	// LinkCopy places an independent copy of the file
+	LinkCopy LinkMode = "copy"
+	// LinkHardlink shares the file with the store, or copies it if the store
+	// is on another file system
+	LinkHardlink LinkMode = "hardlink"
+	// LinkReflink places a copy on write clone, or a copy if the file system
+	// does not support them
+	LinkReflink LinkMode = "reflink"
+)
+
+func (m LinkMode) IsValid() bool {
+	switch m {
+	case LinkCopy, LinkHardlink, LinkReflink:
+		return true
+	}
+	return false
+}
+
+// Store keeps downloaded files by their hash, so that installations on the
+// same host download and keep every file only once. Files are verified
+// against their hash whenever they are taken from the store.
+//
+// Stored files are read-only. With LinkHardlink this applies to the installed
+// files as well, as they are the same file: writing to one in place would
+// change it for every installation.
+type Store struct {
+	Dir  string
+	Link LinkMode
+}
+
+// DefaultStoreDir returns the store in the user cache directory, e.g.
+// ~/.cache/packwiz-installer.
+func DefaultStoreDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "packwiz-installer")
+}
+
+// objectPath returns where the file with the given hash is kept.
+func (s *Store) objectPath(hashFormat, hash string) (string, error) {
+	hash = strings.ToLower(hash)
+	if hash == "" || hashFormat == "" || strings.ContainsAny(hashFormat+hash, `/\.`) {
+		return "", fmt.Errorf("invalid hash %s:%q", hashFormat, hash)
+	}
+	return filepath.Join(s.Dir, "objects", hashFormat, hash), nil
+}
+
+// place installs the file of m at p from the store, and reports whether the
+// store had it. A stored file not matching its hash is removed.
+func (s *Store) place(m *Mod, p string) (bool, error) {
+	obj, err := s.objectPath(m.HashFormat, m.Hash)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(obj); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return false, err
+	}
+
+	if s.Link == LinkHardlink || s.Link == LinkReflink {
+		// the file is shared, so it is verified before it is placed
+		if err := os.Chmod(obj, 0o444); err != nil {
+			return false, err
+		}
+		sums, _, err := hashFile(obj, m.HashFormat)
+		if err != nil {
+			return false, err
+		}
+		if !strings.EqualFold(sums[m.HashFormat], m.Hash) {
+			os.Remove(obj)
+			return false, nil
+		}
+		link := os.Link
+		if s.Link == LinkReflink {
+			link = reflink
+		}
+		err = replaceFile(p, func(tmp string) error {
+			if err := link(obj, tmp); err != nil {
+				return err
+			}
+			if s.Link == LinkReflink {
+				// clones may take over the mode of the object
+				return os.Chmod(tmp, 0o644)
+			}
+			return nil
+		})
+		if err == nil {
+			return true, nil
+		}
+		// fall back to a copy
+	}
+
+	err = writeValidFile(p, m.HashFormat, m.Hash, func(w io.Writer) error {
+		return copyFrom(w, obj)
+	})
+	if errors.Is(err, errHashMismatch) {
+		os.Remove(obj)
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// put adds the verified file of m at p to the store, read-only.
+func (s *Store) put(m *Mod, p string) error {
+	obj, err := s.objectPath(m.HashFormat, m.Hash)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(obj); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(obj), os.ModePerm); err != nil {
+		return err
+	}
+
+	switch s.Link {
+	case LinkHardlink:
+		if err := os.Link(p, obj); err == nil || errors.Is(err, fs.ErrExist) {
+			return os.Chmod(obj, 0o444)
+		}
+	case LinkReflink:
+		err := replaceFile(obj, func(tmp string) error {
+			if err := reflink(p, tmp); err != nil {
+				return err
+			}
+			return os.Chmod(tmp, 0o444)
+		})
+		if err == nil {
+			return nil
+		}
+	}
+	return replaceFile(obj, func(tmp string) error {
+		f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o444)
+		if err != nil {
+			return err
+		}
+		if err := copyFrom(f, p); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// replaceFile creates a file at p by calling create with a temporary path
+// next to it, which is then renamed to p.
+func replaceFile(p string, create func(tmp string) error) error {
+	f, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	os.Remove(tmp)
+
+	if err := create(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := renameFile(tmp, p); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// renameFile renames oldpath to newpath, also if newpath is read-only like a
+// file hardlinked from a store, which windows refuses to replace.
+func renameFile(oldpath, newpath string) error {
+	err := os.Rename(oldpath, newpath)
+	if err != nil && runtime.GOOS == "windows" {
+		// os.Remove clears the read-only attribute
+		if os.Remove(newpath) == nil {
+			return os.Rename(oldpath, newpath)
+		}
+	}
+	return err
+}
+
+func copyFrom(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// register records dir as an installation using the store, its installed
+// files are kept by GC.
+func (s *Store) register(dir string) error {
+	p := filepath.Join(s.Dir, "instances", sha256Hex([]byte(dir)))
+	if data, err := os.ReadFile(p); err == nil && string(data) == dir {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(p, []byte(dir), 0o644)
+}
+
+// GC removes the objects no registered installation has installed, and
+// forgets installations that were deleted. It returns the number and total
+// size of the removed objects.
+func (s *Store) GC() (int, int64, error) {
+	start := time.Now()
+	used, err := s.usedObjects()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		removed int
+		size    int64
+	)
+	root := filepath.Join(s.Dir, "objects")
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == root {
+			return fs.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if used[filepath.ToSlash(rel)] {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		// added by an install that started after the installations were read
+		if fi.ModTime().After(start) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed++
+		size += fi.Size()
+		return nil
+	})
+	return removed, size, err
+}
+
+// usedObjects returns the objects installed in registered installations,
+// or about to be installed by a running install, as "<format>/<hash>".
+func (s *Store) usedObjects() (map[string]bool, error) {
+	dir := filepath.Join(s.Dir, "instances")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		instance, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(string(instance)); errors.Is(err, fs.ErrNotExist) {
+			os.Remove(p)
+			continue
+		}
+		for _, name := range []string{"installed.json", "pending.json"} {
+			data, err := os.ReadFile(filepath.Join(string(instance), ".pw-install", name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			var mods []*Mod
+			if err := json.Unmarshal(data, &mods); err != nil {
+				return nil, fmt.Errorf("read %s: %w", instance, err)
+			}
+			for _, m := range mods {
+				used[m.HashFormat+"/"+strings.ToLower(m.Hash)] = true
+			}
+		}
+	}
+	return used, nil
+}
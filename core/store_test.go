@@ -0,0 +1,148 @@
+// Copyright 2025 Gurkan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	var reqs atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		w.Write([]byte("jar " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	newPack := func(names ...string) *Pack {
+		p := &Pack{Name: "test"}
+		for _, name := range names {
+			p.Mods = append(p.Mods, &Mod{
+				Path:       "mods/" + name,
+				Hash:       sha256Hex([]byte("jar /" + name)),
+				HashFormat: "sha256",
+				Side:       Side_Both,
+				Downloads:  &Download{Type: DL_Url, Data: srv.URL + "/" + name},
+			})
+		}
+		return p
+	}
+
+	for _, link := range []LinkMode{LinkCopy, LinkHardlink, LinkReflink} {
+		t.Run(string(link), func(t *testing.T) {
+			store := &Store{Dir: t.TempDir(), Link: link}
+			install := func(dir string, p *Pack) {
+				t.Helper()
+				inst, err := NewLocalInstaller(p, dir, Side_Both)
+				if err != nil {
+					t.Fatal(err)
+				}
+				inst.Store = store
+				if _, err := inst.Install(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				if exists(t, filepath.Join(dir, ".pw-install", "pending.json")) {
+					t.Error("pending.json left behind by a finished install")
+				}
+			}
+
+			a, b := t.TempDir(), t.TempDir()
+			reqs.Store(0)
+			install(a, newPack("a.jar", "b.jar"))
+			install(b, newPack("a.jar", "b.jar"))
+			if n := reqs.Load(); n != 2 {
+				t.Errorf("two installs sent %d requests, want 2", n)
+			}
+			data, err := os.ReadFile(filepath.Join(b, "mods", "a.jar"))
+			if err != nil || string(data) != "jar /a.jar" {
+				t.Errorf("installed content = %q, %v", data, err)
+			}
+			if link == LinkHardlink {
+				fa := must(os.Stat(filepath.Join(a, "mods", "a.jar")))
+				fb := must(os.Stat(filepath.Join(b, "mods", "a.jar")))
+				if !os.SameFile(fa, fb) {
+					t.Error("hardlinked installs do not share the file")
+				}
+			}
+			// stored files can not be changed through an installation
+			obj := must(store.objectPath("sha256", sha256Hex([]byte("jar /a.jar"))))
+			if fi := must(os.Stat(obj)); fi.Mode().Perm()&0o222 != 0 {
+				t.Errorf("stored file mode = %v, want read-only", fi.Mode())
+			}
+
+			// a damaged object is downloaded again
+			os.Remove(obj)
+			writeTestFile(t, obj, "damaged")
+			reqs.Store(0)
+			install(t.TempDir(), newPack("a.jar"))
+			if n := reqs.Load(); n != 1 {
+				t.Errorf("install from a damaged store sent %d requests, want 1", n)
+			}
+		})
+	}
+}
+
+func TestStore_GC(t *testing.T) {
+	store := &Store{Dir: t.TempDir(), Link: LinkCopy}
+	dirs := []string{t.TempDir(), t.TempDir()}
+	files := []string{"a", "b"}
+	for i, dir := range dirs {
+		pack := newTestPack(map[string]string{"shared.jar": "shared", "own.jar": files[i]})
+		inst, err := NewLocalInstaller(pack, dir, Side_Both)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := inst.Install(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.register(inst.BaseDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a running install, which has not installed anything yet
+	running, err := NewLocalInstaller(&Pack{}, t.TempDir(), Side_Both)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := []*Mod{{Path: "pending.jar", HashFormat: "sha256", Hash: sha256Hex([]byte("pending"))}}
+	if err := running.saveCache("pending", pending); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.register(running.BaseDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"shared", "a", "b", "pending", "unused"} {
+		writeTestFile(t, must(store.objectPath("sha256", sha256Hex([]byte(content)))), content)
+	}
+
+	stored := func(content string) bool {
+		return exists(t, must(store.objectPath("sha256", sha256Hex([]byte(content)))))
+	}
+	if removed, _, err := store.GC(); err != nil || removed != 1 {
+		t.Fatalf("GC() = %d, %v, want 1 removed", removed, err)
+	}
+	if stored("unused") || !stored("shared") || !stored("a") || !stored("pending") {
+		t.Error("GC() removed the wrong files")
+	}
+
+	// deleted installations release their files
+	if err := os.RemoveAll(dirs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if removed, _, err := store.GC(); err != nil || removed != 1 {
+		t.Fatalf("GC() = %d, %v, want 1 removed", removed, err)
+	}
+	if stored("a") || !stored("shared") || !stored("b") {
+		t.Error("GC() removed the wrong files")
+	}
+}